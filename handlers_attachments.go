@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/seiobata/chirpy/internal/auth"
+	"github.com/seiobata/chirpy/internal/database"
+	"github.com/seiobata/chirpy/internal/media"
+)
+
+type Attachment struct {
+	ID          uuid.UUID `json:"id"`
+	URL         string    `json:"url"`
+	AltText     string    `json:"alt_text"`
+	ContentType string    `json:"content_type"`
+	Width       int32     `json:"width"`
+	Height      int32     `json:"height"`
+}
+
+func (cfg *apiConfig) helperAttachmentFromDB(dbAttachment database.ChirpAttachment) Attachment {
+	return Attachment{
+		ID:          dbAttachment.ID,
+		URL:         cfg.blobs.URL(dbAttachment.BlobKey),
+		AltText:     dbAttachment.AltText,
+		ContentType: dbAttachment.ContentType,
+		Width:       dbAttachment.Width,
+		Height:      dbAttachment.Height,
+	}
+}
+
+// helperApplyAttachments fills in the attachments of chirps in place.
+func (cfg *apiConfig) helperApplyAttachments(ctx context.Context, chirps []Chirp) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+	chirpIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.ID)
+	}
+
+	dbAttachments, err := cfg.db.GetAttachmentsForChirps(ctx, chirpIDs)
+	if err != nil {
+		return err
+	}
+	byChirp := map[uuid.UUID][]Attachment{}
+	for _, dbAttachment := range dbAttachments {
+		byChirp[dbAttachment.ChirpID] = append(byChirp[dbAttachment.ChirpID], cfg.helperAttachmentFromDB(dbAttachment))
+	}
+	for i := range chirps {
+		if chirps[i].Deleted {
+			continue
+		}
+		if attachments, ok := byChirp[chirps[i].ID]; ok {
+			chirps[i].Attachments = attachments
+		}
+	}
+	return nil
+}
+
+// helperDeleteBlobs removes stored files after their rows are gone. Failures
+// only leave orphaned files behind, so they are logged rather than returned.
+func (cfg *apiConfig) helperDeleteBlobs(ctx context.Context, dbAttachments []database.ChirpAttachment) {
+	for _, dbAttachment := range dbAttachments {
+		if err := cfg.blobs.Delete(ctx, dbAttachment.BlobKey); err != nil {
+			log.Printf("Failed to delete blob %s: %v", dbAttachment.BlobKey, err)
+		}
+	}
+}
+
+func (cfg *apiConfig) handlerUploadAttachment(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		idErr := fmt.Sprintf("Invalid ID: %v", err)
+		helperResponseError(w, http.StatusBadRequest, idErr)
+		return
+	}
+
+	// validate token
+	user, err := cfg.helperAuthenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	// read the image, leaving headroom in the body limit for form fields
+	r.Body = http.MaxBytesReader(w, r.Body, maxImageBytes+1<<20)
+	err = r.ParseMultipartForm(maxImageBytes)
+	if err != nil {
+		formErr := fmt.Sprintf("Error parsing multipart form: %v", err)
+		helperResponseError(w, http.StatusBadRequest, formErr)
+		return
+	}
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		fileErr := fmt.Sprintf("Missing image file: %v", err)
+		helperResponseError(w, http.StatusBadRequest, fileErr)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxImageBytes+1))
+	if err != nil {
+		readErr := fmt.Sprintf("Error reading image: %v", err)
+		helperResponseError(w, http.StatusBadRequest, readErr)
+		return
+	}
+	if len(data) > maxImageBytes {
+		sizeErr := fmt.Sprintf("Image must be at most %d bytes", maxImageBytes)
+		helperResponseError(w, http.StatusRequestEntityTooLarge, sizeErr)
+		return
+	}
+	altText := r.FormValue("alt_text")
+	if len([]rune(altText)) > maxAltTextLength {
+		altErr := fmt.Sprintf("Alt text must be at most %d characters", maxAltTextLength)
+		helperResponseError(w, http.StatusBadRequest, altErr)
+		return
+	}
+
+	img, err := media.ProcessImage(data, maxImageDimension)
+	if err != nil {
+		imageErr := fmt.Sprintf("Invalid image: %v", err)
+		code := http.StatusBadRequest
+		if errors.Is(err, media.ErrUnsupportedType) {
+			code = http.StatusUnsupportedMediaType
+		}
+		helperResponseError(w, code, imageErr)
+		return
+	}
+
+	// the attachment limit depends on the uploader's plan
+	entitlements, err := cfg.helperEntitlements(r.Context(), user)
+	if err != nil {
+		userErr := fmt.Sprintf("Error retrieving user: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, userErr)
+		return
+	}
+
+	// store the file before locking the chirp so slow storage doesn't hold
+	// the lock; it is removed again unless its row is committed
+	attachmentID := uuid.New()
+	key := fmt.Sprintf("chirps/%s/%s%s", chirpID, attachmentID, img.Extension)
+	err = cfg.blobs.Put(r.Context(), key, bytes.NewReader(img.Data))
+	if err != nil {
+		putErr := fmt.Sprintf("Error storing image: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, putErr)
+		return
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			cfg.helperDeleteBlobs(context.WithoutCancel(r.Context()), []database.ChirpAttachment{{BlobKey: key}})
+		}
+	}()
+
+	// lock the chirp so concurrent uploads cannot exceed the limit
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		txErr := fmt.Sprintf("Error starting transaction: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, txErr)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	chirp, err := qtx.GetAChirpForUpdate(r.Context(), chirpID)
+	if err != nil {
+		getAChirpErr := fmt.Sprintf("Error retrieving chirp: %v", err)
+		helperResponseError(w, http.StatusNotFound, getAChirpErr)
+		return
+	}
+
+	// verify chirp owner
+	if chirp.UserID != user {
+		userErr := "User not allowed to add attachments to chirp"
+		helperResponseError(w, http.StatusForbidden, userErr)
+		return
+	}
+	if chirp.Kind == chirpKindRechirp {
+		rechirpErr := "Rechirps cannot have attachments"
+		helperResponseError(w, http.StatusBadRequest, rechirpErr)
+		return
+	}
+	count, err := qtx.CountChirpAttachments(r.Context(), chirpID)
+	if err != nil {
+		countErr := fmt.Sprintf("Error counting attachments: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, countErr)
+		return
+	}
+	if count >= int64(entitlements.MaxAttachmentsPerChirp) {
+		limitErr := fmt.Sprintf("A chirp can have at most %d attachments", entitlements.MaxAttachmentsPerChirp)
+		helperResponseError(w, http.StatusBadRequest, limitErr)
+		return
+	}
+
+	dbAttachment, err := qtx.CreateChirpAttachment(r.Context(), database.CreateChirpAttachmentParams{
+		ID:          attachmentID,
+		ChirpID:     chirpID,
+		BlobKey:     key,
+		ContentType: img.ContentType,
+		Width:       int32(img.Width),
+		Height:      int32(img.Height),
+		AltText:     altText,
+		Position:    int32(count),
+	})
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		createErr := fmt.Sprintf("Error saving attachment: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, createErr)
+		return
+	}
+	committed = true
+
+	helperResponseJSON(w, http.StatusCreated, cfg.helperAttachmentFromDB(dbAttachment))
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/seiobata/chirpy/internal/auth"
+	"github.com/seiobata/chirpy/internal/database"
+)
+
+func (cfg *apiConfig) handlerBookmarkChirp(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		idErr := fmt.Sprintf("Invalid ID: %v", err)
+		helperResponseError(w, http.StatusBadRequest, idErr)
+		return
+	}
+
+	// validate token
+	user, err := cfg.helperAuthenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	_, err = cfg.db.GetAChirp(r.Context(), chirpID)
+	if err != nil {
+		getAChirpErr := fmt.Sprintf("Error retrieving chirp: %v", err)
+		helperResponseError(w, http.StatusNotFound, getAChirpErr)
+		return
+	}
+
+	// bookmarking twice is a no-op
+	err = cfg.db.AddBookmark(r.Context(), database.AddBookmarkParams{
+		UserID:  user,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		bookmarkErr := fmt.Sprintf("Error bookmarking chirp: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, bookmarkErr)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnbookmarkChirp(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		idErr := fmt.Sprintf("Invalid ID: %v", err)
+		helperResponseError(w, http.StatusBadRequest, idErr)
+		return
+	}
+
+	// validate token
+	user, err := cfg.helperAuthenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	err = cfg.db.RemoveBookmark(r.Context(), database.RemoveBookmarkParams{
+		UserID:  user,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		bookmarkErr := fmt.Sprintf("Error removing bookmark: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, bookmarkErr)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerGetMyBookmarks(w http.ResponseWriter, r *http.Request) {
+	// validate token
+	user, err := cfg.helperAuthenticate(r, auth.ScopeChirpsRead)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	limit, err := helperPageLimit(r)
+	if err != nil {
+		limitErr := fmt.Sprintf("Invalid limit: %v", err)
+		helperResponseError(w, http.StatusBadRequest, limitErr)
+		return
+	}
+
+	// resume after the cursor position if present
+	afterCreatedAt := sql.NullTime{}
+	afterID := uuid.NullUUID{}
+	cursor := r.URL.Query().Get("cursor")
+	if cursor != "" {
+		createdAt, id, err := helperDecodeCursor(cursor)
+		if err != nil {
+			cursorErr := fmt.Sprintf("Invalid cursor: %v", err)
+			helperResponseError(w, http.StatusBadRequest, cursorErr)
+			return
+		}
+		afterCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		afterID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	// deleted chirps drop out here but keep their bookmark in case they are restored
+	rows, err := cfg.db.GetBookmarksPage(r.Context(), database.GetBookmarksPageParams{
+		UserID:         user,
+		AfterCreatedAt: afterCreatedAt,
+		AfterID:        afterID,
+		Limit:          int32(limit + 1),
+	})
+	if err != nil {
+		bookmarksErr := fmt.Sprintf("Error retrieving bookmarks: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, bookmarksErr)
+		return
+	}
+
+	// the list is ordered by when each chirp was saved, not when it was posted
+	page := ChirpPage{}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[len(rows)-1]
+		page.NextCursor = helperEncodeCursor(last.BookmarkedAt, last.Chirp.ID)
+	}
+	dbChirps := make([]database.Chirp, 0, len(rows))
+	for _, row := range rows {
+		dbChirps = append(dbChirps, row.Chirp)
+	}
+	page.Chirps, err = cfg.helperHydrateChirps(r.Context(), uuid.NullUUID{UUID: user, Valid: true}, dbChirps)
+	if err != nil {
+		hydrateErr := fmt.Sprintf("Error loading chirp details: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, hydrateErr)
+		return
+	}
+	helperResponseJSON(w, http.StatusOK, page)
+}
@@ -1,41 +1,208 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
-	"sort"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/seiobata/chirpy/internal/auth"
 	"github.com/seiobata/chirpy/internal/database"
+	"github.com/seiobata/chirpy/internal/search"
+)
+
+const (
+	chirpKindOriginal = "original"
+	chirpKindRechirp  = "rechirp"
+	chirpKindQuote    = "quote"
+
+	unavailableChirpText = "chirp unavailable"
 )
 
 type Chirp struct {
+	ID                uuid.UUID      `json:"id"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	Body              string         `json:"body"`
+	UserID            uuid.UUID      `json:"user_id"`
+	ParentChirpID     uuid.NullUUID  `json:"parent_chirp_id"`
+	Deleted           bool           `json:"deleted,omitempty"`
+	LikeCount         int32          `json:"like_count"`
+	LikedByMe         bool           `json:"liked_by_me"`
+	Kind              string         `json:"kind"`
+	ReferencedChirpID uuid.NullUUID  `json:"referenced_chirp_id"`
+	ReferencedChirp   *EmbeddedChirp `json:"referenced_chirp,omitempty"`
+	Attachments       []Attachment   `json:"attachments"`
+	PublishAt         *time.Time     `json:"publish_at,omitempty"`
+	Poll              *Poll          `json:"poll,omitempty"`
+}
+
+// EmbeddedChirp is the original shown inside a rechirp or quote. When the
+// original has been deleted only Unavailable and Placeholder are set.
+type EmbeddedChirp struct {
+	*Chirp
+	Unavailable bool   `json:"unavailable,omitempty"`
+	Placeholder string `json:"placeholder,omitempty"`
+}
+
+func helperChirpFromDB(dbChirp database.Chirp) Chirp {
+	chirp := Chirp{
+		ID:                dbChirp.ID,
+		CreatedAt:         dbChirp.CreatedAt,
+		UpdatedAt:         dbChirp.UpdatedAt,
+		Body:              dbChirp.Body,
+		UserID:            dbChirp.UserID,
+		ParentChirpID:     dbChirp.ParentChirpID,
+		Deleted:           dbChirp.DeletedAt.Valid,
+		LikeCount:         dbChirp.LikeCount,
+		Kind:              dbChirp.Kind,
+		ReferencedChirpID: dbChirp.ReferencedChirpID,
+		Attachments:       []Attachment{},
+	}
+	// tombstones keep their body for restores but never show it
+	if chirp.Deleted {
+		chirp.Body = ""
+	}
+	// only pending chirps report when they go out
+	if !dbChirp.Published && dbChirp.PublishAt.Valid {
+		chirp.PublishAt = &dbChirp.PublishAt.Time
+	}
+	return chirp
+}
+
+// helperHydrateChirps converts database rows into API chirps, embeds the
+// originals of rechirps and quotes, lists their attachments and polls and
+// fills in the fields that depend on who is viewing them. Each step costs
+// one query per batch.
+func (cfg *apiConfig) helperHydrateChirps(ctx context.Context, viewer uuid.NullUUID, dbChirps []database.Chirp) ([]Chirp, error) {
+	chirps := make([]Chirp, 0, len(dbChirps))
+	refIDs := []uuid.UUID{}
+	for _, dbChirp := range dbChirps {
+		chirps = append(chirps, helperChirpFromDB(dbChirp))
+		if dbChirp.ReferencedChirpID.Valid {
+			refIDs = append(refIDs, dbChirp.ReferencedChirpID.UUID)
+		}
+	}
+
+	// originals are embedded one level deep
+	refs := []Chirp{}
+	if len(refIDs) > 0 {
+		dbRefs, err := cfg.db.GetChirpsByIDs(ctx, refIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, dbRef := range dbRefs {
+			if !dbRef.DeletedAt.Valid {
+				refs = append(refs, helperChirpFromDB(dbRef))
+			}
+		}
+	}
+
+	// originals share the batched lookups with the chirps embedding them
+	count := len(chirps)
+	all := append(chirps, refs...)
+	err := cfg.helperApplyViewerState(ctx, viewer, all)
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.helperApplyAttachments(ctx, all)
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.helperApplyPolls(ctx, viewer, all)
+	if err != nil {
+		return nil, err
+	}
+	chirps, refs = all[:count], all[count:]
+
+	byID := map[uuid.UUID]*Chirp{}
+	for i := range refs {
+		byID[refs[i].ID] = &refs[i]
+	}
+	for i := range chirps {
+		if chirps[i].Kind == chirpKindOriginal {
+			continue
+		}
+		ref, ok := byID[chirps[i].ReferencedChirpID.UUID]
+		if !chirps[i].ReferencedChirpID.Valid || !ok {
+			chirps[i].ReferencedChirp = &EmbeddedChirp{
+				Unavailable: true,
+				Placeholder: unavailableChirpText,
+			}
+			continue
+		}
+		chirps[i].ReferencedChirp = &EmbeddedChirp{Chirp: ref}
+	}
+	return chirps, nil
+}
+
+// helperApplyViewerState sets the per-viewer flags on chirps in place.
+func (cfg *apiConfig) helperApplyViewerState(ctx context.Context, viewer uuid.NullUUID, chirps []Chirp) error {
+	if !viewer.Valid || len(chirps) == 0 {
+		return nil
+	}
+	chirpIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.ID)
+	}
+
+	likedIDs, err := cfg.db.GetLikedChirpIDs(ctx, database.GetLikedChirpIDsParams{
+		UserID:   viewer.UUID,
+		ChirpIds: chirpIDs,
+	})
+	if err != nil {
+		return err
+	}
+	liked := map[uuid.UUID]bool{}
+	for _, id := range likedIDs {
+		liked[id] = true
+	}
+	for i := range chirps {
+		chirps[i].LikedByMe = liked[chirps[i].ID]
+	}
+	return nil
+}
+
+func (cfg *apiConfig) helperHydrateChirp(ctx context.Context, viewer uuid.NullUUID, dbChirp database.Chirp) (Chirp, error) {
+	chirps, err := cfg.helperHydrateChirps(ctx, viewer, []database.Chirp{dbChirp})
+	if err != nil {
+		return Chirp{}, err
+	}
+	return chirps[0], nil
+}
+
+type ChirpRevision struct {
 	ID        uuid.UUID `json:"id"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
 	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+}
+
+type ChirpPage struct {
+	Chirps     []Chirp `json:"chirps"`
+	NextCursor string  `json:"next_cursor,omitempty"`
 }
 
 func (cfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
-		Body string `json:"body"`
+		Body              string          `json:"body"`
+		ParentChirpID     uuid.NullUUID   `json:"parent_chirp_id"`
+		Kind              string          `json:"kind"`
+		ReferencedChirpID uuid.NullUUID   `json:"referenced_chirp_id"`
+		PublishAt         *time.Time      `json:"publish_at"`
+		Poll              *pollParameters `json:"poll"`
 	}
 	params := parameters{}
 
 	// check token
-	invalidErr := "Invalid token"
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		helperResponseError(w, http.StatusUnauthorized, invalidErr)
-		return
-	}
-	validID, err := auth.ValidateJWT(token, cfg.secret)
+	validID, err := cfg.helperAuthenticate(r, auth.ScopeChirpsWrite)
 	if err != nil {
-		helperResponseError(w, http.StatusUnauthorized, invalidErr)
+		helperAuthError(w, err)
 		return
 	}
 
@@ -47,71 +214,343 @@ func (cfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	validBody, err := helperValidateBody(params.Body)
+	// length and rate limits depend on the author's plan
+	entitlements, err := cfg.helperEntitlements(r.Context(), validID)
 	if err != nil {
-		validateBodyErr := fmt.Sprintf("Invalid chirp: %v", err)
-		helperResponseError(w, http.StatusBadRequest, validateBodyErr)
+		userErr := fmt.Sprintf("Error retrieving user: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, userErr)
+		return
+	}
+
+	// rechirps and quotes must reference another chirp; originals must not
+	if params.Kind == "" {
+		params.Kind = chirpKindOriginal
+	}
+	switch params.Kind {
+	case chirpKindOriginal:
+		if params.ReferencedChirpID.Valid {
+			kindErr := "Invalid chirp: only rechirps and quotes can reference a chirp"
+			helperResponseError(w, http.StatusBadRequest, kindErr)
+			return
+		}
+	case chirpKindRechirp, chirpKindQuote:
+		if !params.ReferencedChirpID.Valid {
+			kindErr := fmt.Sprintf("Invalid chirp: a %s must reference a chirp", params.Kind)
+			helperResponseError(w, http.StatusBadRequest, kindErr)
+			return
+		}
+	default:
+		kindErr := fmt.Sprintf("Invalid chirp: unknown kind %q", params.Kind)
+		helperResponseError(w, http.StatusBadRequest, kindErr)
+		return
+	}
+
+	// a rechirp has no text of its own
+	validBody := ""
+	flagged := []uuid.UUID{}
+	if params.Kind == chirpKindRechirp {
+		if params.Body != "" || params.ParentChirpID.Valid {
+			rechirpErr := "Invalid chirp: a rechirp cannot have a body or a parent"
+			helperResponseError(w, http.StatusBadRequest, rechirpErr)
+			return
+		}
+	} else {
+		validBody, flagged, err = cfg.helperValidateBody(params.Body, entitlements.MaxChirpLength)
+		if err != nil {
+			validateBodyErr := fmt.Sprintf("Invalid chirp: %v", err)
+			helperResponseError(w, http.StatusBadRequest, validateBodyErr)
+			return
+		}
+	}
+
+	// scheduled chirps stay hidden until the publisher picks them up
+	publishAt := sql.NullTime{}
+	if params.PublishAt != nil {
+		err = helperValidatePublishAt(*params.PublishAt)
+		if err != nil {
+			scheduleErr := fmt.Sprintf("Invalid publish_at: %v", err)
+			helperResponseError(w, http.StatusBadRequest, scheduleErr)
+			return
+		}
+		publishAt = sql.NullTime{Time: params.PublishAt.UTC(), Valid: true}
+	}
+
+	// polls open when the chirp is published
+	pollOptions := []string{}
+	if params.Poll != nil {
+		if params.Kind == chirpKindRechirp {
+			pollErr := "Invalid chirp: a rechirp cannot have a poll"
+			helperResponseError(w, http.StatusBadRequest, pollErr)
+			return
+		}
+		opensAt := time.Now()
+		if publishAt.Valid {
+			opensAt = publishAt.Time
+		}
+		pollOptions, err = helperValidatePoll(*params.Poll, opensAt)
+		if err != nil {
+			pollErr := fmt.Sprintf("Invalid poll: %v", err)
+			helperResponseError(w, http.StatusBadRequest, pollErr)
+			return
+		}
+	}
+
+	// point at the original rather than at another rechirp
+	if params.ReferencedChirpID.Valid {
+		ref, err := cfg.db.GetAChirp(r.Context(), params.ReferencedChirpID.UUID)
+		if err != nil {
+			refErr := fmt.Sprintf("Invalid referenced chirp: %v", err)
+			helperResponseError(w, http.StatusBadRequest, refErr)
+			return
+		}
+		if ref.Kind == chirpKindRechirp {
+			params.ReferencedChirpID = ref.ReferencedChirpID
+		}
+	}
+	if params.Kind == chirpKindRechirp {
+		rechirped, err := cfg.db.UserHasRechirped(r.Context(), database.UserHasRechirpedParams{
+			UserID:  validID,
+			ChirpID: params.ReferencedChirpID.UUID,
+		})
+		if err != nil {
+			rechirpErr := fmt.Sprintf("Error checking rechirps: %v", err)
+			helperResponseError(w, http.StatusInternalServerError, rechirpErr)
+			return
+		}
+		if rechirped {
+			conflictErr := "Chirp already rechirped"
+			helperResponseError(w, http.StatusConflict, conflictErr)
+			return
+		}
 	}
-	chirp, err := cfg.db.CreateChirp(r.Context(), database.CreateChirpParams{
-		Body:   validBody,
-		UserID: validID,
+
+	// replies must point at a chirp that still exists
+	if params.ParentChirpID.Valid {
+		_, err = cfg.db.GetAChirp(r.Context(), params.ParentChirpID.UUID)
+		if err != nil {
+			parentErr := fmt.Sprintf("Invalid parent chirp: %v", err)
+			helperResponseError(w, http.StatusBadRequest, parentErr)
+			return
+		}
+	}
+
+	// only chirps that passed validation count against the rate limit
+	if !cfg.helperAllowChirp(w, validID, entitlements) {
+		return
+	}
+
+	// store the chirp with its hashtags, mentions and poll
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		txErr := fmt.Sprintf("Error starting transaction: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, txErr)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	dbChirp, err := qtx.CreateChirp(r.Context(), database.CreateChirpParams{
+		Body:              validBody,
+		UserID:            validID,
+		ParentChirpID:     params.ParentChirpID,
+		Kind:              params.Kind,
+		ReferencedChirpID: params.ReferencedChirpID,
+		Published:         !publishAt.Valid,
+		PublishAt:         publishAt,
 	})
+	// a rechirp sent twice at once passes the check above both times
+	if helperIsUniqueViolation(err, "chirps_one_rechirp_per_user_idx") {
+		conflictErr := "Chirp already rechirped"
+		helperResponseError(w, http.StatusConflict, conflictErr)
+		return
+	}
 	if err != nil {
 		createChirpErr := fmt.Sprintf("Error creating chirp: %v", err)
 		helperResponseError(w, http.StatusInternalServerError, createChirpErr)
 		return
 	}
-	helperResponseJSON(w, http.StatusCreated, Chirp{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
-	})
+	err = helperSaveHashtags(r.Context(), qtx, dbChirp)
+	if err != nil {
+		hashtagsErr := fmt.Sprintf("Error saving hashtags: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, hashtagsErr)
+		return
+	}
+	err = helperSaveMentions(r.Context(), qtx, dbChirp)
+	if err != nil {
+		mentionsErr := fmt.Sprintf("Error saving mentions: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, mentionsErr)
+		return
+	}
+	err = helperSaveFlags(r.Context(), qtx, dbChirp.ID, flagged)
+	if err != nil {
+		flagsErr := fmt.Sprintf("Error saving content flags: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, flagsErr)
+		return
+	}
+	if params.Poll != nil {
+		err = helperSavePoll(r.Context(), qtx, dbChirp.ID, pollOptions, params.Poll.ClosesAt)
+		if err != nil {
+			pollErr := fmt.Sprintf("Error saving poll: %v", err)
+			helperResponseError(w, http.StatusInternalServerError, pollErr)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		commitErr := fmt.Sprintf("Error committing chirp: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, commitErr)
+		return
+	}
+	chirp, err := cfg.helperHydrateChirp(r.Context(), uuid.NullUUID{UUID: validID, Valid: true}, dbChirp)
+	if err != nil {
+		hydrateErr := fmt.Sprintf("Error loading chirp details: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, hydrateErr)
+		return
+	}
+	helperResponseJSON(w, http.StatusCreated, chirp)
 }
 
 func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
-	// retrieve all chirps from database
-	dbChirps, err := cfg.db.GetChirps(r.Context())
+	// personalize the response when a token is supplied
+	viewer, err := cfg.helperOptionalUser(r)
 	if err != nil {
-		getChirpsErr := fmt.Sprintf("Error retrieving all chirps: %v", err)
-		helperResponseError(w, http.StatusInternalServerError, getChirpsErr)
+		helperAuthError(w, err)
 		return
 	}
 
 	// filter by author_id if present
-	userID := uuid.Nil
+	authorID := uuid.NullUUID{}
 	authID := r.URL.Query().Get("author_id")
 	if authID != "" {
-		userID, err = uuid.Parse(authID)
+		userID, err := uuid.Parse(authID)
 		if err != nil {
 			parseErr := fmt.Sprintf("Error parsing UUID: %v", err)
-			helperResponseError(w, http.StatusInternalServerError, parseErr)
+			helperResponseError(w, http.StatusBadRequest, parseErr)
 			return
 		}
+		authorID = uuid.NullUUID{UUID: userID, Valid: true}
 	}
 
-	// sort in descending order if specified
+	// clients that don't page keep getting a bare array, but only of the
+	// first defaultPageLimit chirps; reading further takes a limit or cursor,
+	// which returns a page with its next_cursor
+	paged := r.URL.Query().Has("cursor") || r.URL.Query().Has("limit")
+	limit, err := helperPageLimit(r)
+	if err != nil {
+		limitErr := fmt.Sprintf("Invalid limit: %v", err)
+		helperResponseError(w, http.StatusBadRequest, limitErr)
+		return
+	}
+
+	// resume after the cursor position if present
+	afterCreatedAt := sql.NullTime{}
+	afterID := uuid.NullUUID{}
+	cursor := r.URL.Query().Get("cursor")
+	if cursor != "" {
+		createdAt, id, err := helperDecodeCursor(cursor)
+		if err != nil {
+			cursorErr := fmt.Sprintf("Invalid cursor: %v", err)
+			helperResponseError(w, http.StatusBadRequest, cursorErr)
+			return
+		}
+		afterCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		afterID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	// fetch one extra row to learn whether another page follows
+	var dbChirps []database.Chirp
 	sortOrder := r.URL.Query().Get("sort")
 	if sortOrder == "desc" {
-		sort.Slice(dbChirps, func(i, j int) bool {
-			return dbChirps[i].CreatedAt.After(dbChirps[j].CreatedAt)
+		dbChirps, err = cfg.db.GetChirpsPageDesc(r.Context(), database.GetChirpsPageDescParams{
+			AuthorID:       authorID,
+			AfterCreatedAt: afterCreatedAt,
+			AfterID:        afterID,
+			Limit:          int32(limit + 1),
+		})
+	} else {
+		dbChirps, err = cfg.db.GetChirpsPageAsc(r.Context(), database.GetChirpsPageAscParams{
+			AuthorID:       authorID,
+			AfterCreatedAt: afterCreatedAt,
+			AfterID:        afterID,
+			Limit:          int32(limit + 1),
 		})
 	}
+	if err != nil {
+		getChirpsErr := fmt.Sprintf("Error retrieving chirps: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, getChirpsErr)
+		return
+	}
 
-	chirps := []Chirp{}
-	for _, dbChirp := range dbChirps {
-		// apply author_id filter
-		if authID != "" && dbChirp.UserID != userID {
-			continue
+	page := ChirpPage{}
+	if len(dbChirps) > limit {
+		dbChirps = dbChirps[:limit]
+		last := dbChirps[len(dbChirps)-1]
+		page.NextCursor = helperEncodeCursor(last.CreatedAt, last.ID)
+	}
+	page.Chirps, err = cfg.helperHydrateChirps(r.Context(), viewer, dbChirps)
+	if err != nil {
+		hydrateErr := fmt.Sprintf("Error loading chirp details: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, hydrateErr)
+		return
+	}
+	if !paged {
+		helperResponseJSON(w, http.StatusOK, page.Chirps)
+		return
+	}
+	helperResponseJSON(w, http.StatusOK, page)
+}
+
+func (cfg *apiConfig) handlerSearchChirps(w http.ResponseWriter, r *http.Request) {
+	// personalize the response when a token is supplied
+	viewer, err := cfg.helperOptionalUser(r)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	query, err := search.BuildTSQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		queryErr := fmt.Sprintf("Invalid search query: %v", err)
+		helperResponseError(w, http.StatusBadRequest, queryErr)
+		return
+	}
+
+	// filter by author_id if present
+	authorID := uuid.NullUUID{}
+	authID := r.URL.Query().Get("author_id")
+	if authID != "" {
+		userID, err := uuid.Parse(authID)
+		if err != nil {
+			parseErr := fmt.Sprintf("Error parsing UUID: %v", err)
+			helperResponseError(w, http.StatusBadRequest, parseErr)
+			return
 		}
-		chirps = append(chirps, Chirp{
-			ID:        dbChirp.ID,
-			CreatedAt: dbChirp.CreatedAt,
-			UpdatedAt: dbChirp.UpdatedAt,
-			Body:      dbChirp.Body,
-			UserID:    dbChirp.UserID,
-		})
+		authorID = uuid.NullUUID{UUID: userID, Valid: true}
+	}
+
+	limit, err := helperPageLimit(r)
+	if err != nil {
+		limitErr := fmt.Sprintf("Invalid limit: %v", err)
+		helperResponseError(w, http.StatusBadRequest, limitErr)
+		return
+	}
+
+	// results come back best match first
+	dbChirps, err := cfg.db.SearchChirps(r.Context(), database.SearchChirpsParams{
+		Query:    query,
+		AuthorID: authorID,
+		Limit:    int32(limit),
+	})
+	if err != nil {
+		searchErr := fmt.Sprintf("Error searching chirps: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, searchErr)
+		return
+	}
+
+	chirps, err := cfg.helperHydrateChirps(r.Context(), viewer, dbChirps)
+	if err != nil {
+		hydrateErr := fmt.Sprintf("Error loading chirp details: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, hydrateErr)
+		return
 	}
 	helperResponseJSON(w, http.StatusOK, chirps)
 }
@@ -123,19 +562,187 @@ func (cfg *apiConfig) handlerGetAChirp(w http.ResponseWriter, r *http.Request) {
 		helperResponseError(w, http.StatusBadRequest, idErr)
 		return
 	}
-	chirp, err := cfg.db.GetAChirp(r.Context(), chirpID)
+
+	// personalize the response when a token is supplied
+	viewer, err := cfg.helperOptionalUser(r)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+	dbChirp, err := cfg.db.GetAChirp(r.Context(), chirpID)
 	if err != nil {
 		getAChirpErr := fmt.Sprintf("Error retrieving chirp: %v", err)
 		helperResponseError(w, http.StatusNotFound, getAChirpErr)
 		return
 	}
-	helperResponseJSON(w, http.StatusOK, Chirp{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
+	chirp, err := cfg.helperHydrateChirp(r.Context(), viewer, dbChirp)
+	if err != nil {
+		hydrateErr := fmt.Sprintf("Error loading chirp details: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, hydrateErr)
+		return
+	}
+	helperResponseJSON(w, http.StatusOK, chirp)
+}
+
+func (cfg *apiConfig) handlerUpdateAChirp(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		idErr := fmt.Sprintf("Invalid ID: %v", err)
+		helperResponseError(w, http.StatusBadRequest, idErr)
+		return
+	}
+
+	// validate token
+	user, err := cfg.helperAuthenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&params)
+	if err != nil {
+		decodeErr := fmt.Sprintf("Error decoding JSON: %v", err)
+		helperResponseError(w, http.StatusBadRequest, decodeErr)
+		return
+	}
+
+	entitlements, err := cfg.helperEntitlements(r.Context(), user)
+	if err != nil {
+		userErr := fmt.Sprintf("Error retrieving user: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, userErr)
+		return
+	}
+	validBody, flagged, err := cfg.helperValidateBody(params.Body, entitlements.MaxChirpLength)
+	if err != nil {
+		validateBodyErr := fmt.Sprintf("Invalid chirp: %v", err)
+		helperResponseError(w, http.StatusBadRequest, validateBodyErr)
+		return
+	}
+
+	// lock the chirp so concurrent edits record revisions in order
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		txErr := fmt.Sprintf("Error starting transaction: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, txErr)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	chirp, err := qtx.GetAChirpForUpdate(r.Context(), chirpID)
+	if err != nil {
+		getAChirpErr := fmt.Sprintf("Error retrieving chirp: %v", err)
+		helperResponseError(w, http.StatusNotFound, getAChirpErr)
+		return
+	}
+
+	// verify chirp owner
+	if chirp.UserID != user {
+		userErr := "User not allowed to edit chirp"
+		helperResponseError(w, http.StatusForbidden, userErr)
+		return
+	}
+	if chirp.Kind == chirpKindRechirp {
+		rechirpErr := "Rechirps cannot be edited"
+		helperResponseError(w, http.StatusBadRequest, rechirpErr)
+		return
+	}
+	// anyone can fix a scheduled chirp before it goes out
+	if chirp.Published && !entitlements.CanEditChirps {
+		planErr := "Editing published chirps requires Chirpy Red"
+		helperResponseError(w, http.StatusForbidden, planErr)
+		return
+	}
+
+	// keep the prior body in the revision history
+	_, err = qtx.CreateChirpRevision(r.Context(), database.CreateChirpRevisionParams{
+		ChirpID: chirp.ID,
+		Body:    chirp.Body,
 	})
+	if err != nil {
+		revisionErr := fmt.Sprintf("Error saving chirp revision: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, revisionErr)
+		return
+	}
+	updated, err := qtx.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+		ID:   chirp.ID,
+		Body: validBody,
+	})
+	if err != nil {
+		updateErr := fmt.Sprintf("Error updating chirp: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, updateErr)
+		return
+	}
+	err = helperSaveHashtags(r.Context(), qtx, updated)
+	if err != nil {
+		hashtagsErr := fmt.Sprintf("Error saving hashtags: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, hashtagsErr)
+		return
+	}
+	err = helperSaveMentions(r.Context(), qtx, updated)
+	if err != nil {
+		mentionsErr := fmt.Sprintf("Error saving mentions: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, mentionsErr)
+		return
+	}
+	err = helperSaveFlags(r.Context(), qtx, updated.ID, flagged)
+	if err != nil {
+		flagsErr := fmt.Sprintf("Error saving content flags: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, flagsErr)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		commitErr := fmt.Sprintf("Error committing chirp update: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, commitErr)
+		return
+	}
+
+	response, err := cfg.helperHydrateChirp(r.Context(), uuid.NullUUID{UUID: user, Valid: true}, updated)
+	if err != nil {
+		hydrateErr := fmt.Sprintf("Error loading chirp details: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, hydrateErr)
+		return
+	}
+	helperResponseJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handlerGetChirpRevisions(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		idErr := fmt.Sprintf("Invalid ID: %v", err)
+		helperResponseError(w, http.StatusBadRequest, idErr)
+		return
+	}
+	_, err = cfg.db.GetAChirp(r.Context(), chirpID)
+	if err != nil {
+		getAChirpErr := fmt.Sprintf("Error retrieving chirp: %v", err)
+		helperResponseError(w, http.StatusNotFound, getAChirpErr)
+		return
+	}
+
+	dbRevisions, err := cfg.db.GetChirpRevisions(r.Context(), chirpID)
+	if err != nil {
+		revisionsErr := fmt.Sprintf("Error retrieving chirp revisions: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, revisionsErr)
+		return
+	}
+
+	revisions := []ChirpRevision{}
+	for _, dbRevision := range dbRevisions {
+		revisions = append(revisions, ChirpRevision{
+			ID:        dbRevision.ID,
+			CreatedAt: dbRevision.CreatedAt,
+			ChirpID:   dbRevision.ChirpID,
+			Body:      dbRevision.Body,
+		})
+	}
+	helperResponseJSON(w, http.StatusOK, revisions)
 }
 
 func (cfg *apiConfig) handlerDeleteAChirp(w http.ResponseWriter, r *http.Request) {
@@ -147,19 +754,23 @@ func (cfg *apiConfig) handlerDeleteAChirp(w http.ResponseWriter, r *http.Request
 	}
 
 	// validate token
-	invalidErr := "Token is invalid or expired"
-	token, err := auth.GetBearerToken(r.Header)
+	user, err := cfg.helperAuthenticate(r, auth.ScopeChirpsWrite)
 	if err != nil {
-		helperResponseError(w, http.StatusUnauthorized, invalidErr)
+		helperAuthError(w, err)
 		return
 	}
-	user, err := auth.ValidateJWT(token, cfg.secret)
+
+	// lock the chirp so an edit cannot race the deletion
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
 	if err != nil {
-		helperResponseError(w, http.StatusUnauthorized, invalidErr)
+		txErr := fmt.Sprintf("Error starting transaction: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, txErr)
 		return
 	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
 
-	chirp, err := cfg.db.GetAChirp(r.Context(), chirpID)
+	chirp, err := qtx.GetAChirpForUpdate(r.Context(), chirpID)
 	if err != nil {
 		getAChirpErr := fmt.Sprintf("Error retrieving chirp: %v", err)
 		helperResponseError(w, http.StatusNotFound, getAChirpErr)
@@ -173,13 +784,157 @@ func (cfg *apiConfig) handlerDeleteAChirp(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// delete chirp
-	err = cfg.db.DeleteAChirp(r.Context(), chirpID)
+	// the chirp keeps its body, revisions and attachments until the purge so
+	// it can be restored; its rechirps share the tombstone and come back with it
+	deletedAt, err := qtx.SoftDeleteChirp(r.Context(), chirpID)
 	if err != nil {
 		deleteAChirpErr := fmt.Sprintf("Error deleting chirp: %v", err)
 		helperResponseError(w, http.StatusInternalServerError, deleteAChirpErr)
 		return
 	}
+	err = qtx.SoftDeleteRechirpsOf(r.Context(), database.SoftDeleteRechirpsOfParams{
+		DeletedAt: deletedAt,
+		ChirpID:   chirpID,
+	})
+	if err != nil {
+		rechirpsErr := fmt.Sprintf("Error deleting rechirps: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, rechirpsErr)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		commitErr := fmt.Sprintf("Error committing chirp deletion: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, commitErr)
+		return
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func (cfg *apiConfig) handlerRestoreAChirp(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		idErr := fmt.Sprintf("Invalid ID: %v", err)
+		helperResponseError(w, http.StatusBadRequest, idErr)
+		return
+	}
+
+	// validate token
+	user, err := cfg.helperAuthenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		txErr := fmt.Sprintf("Error starting transaction: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, txErr)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	chirp, err := qtx.GetDeletedChirpForUpdate(r.Context(), chirpID)
+	if err != nil {
+		getAChirpErr := fmt.Sprintf("Error retrieving deleted chirp: %v", err)
+		helperResponseError(w, http.StatusNotFound, getAChirpErr)
+		return
+	}
+
+	// verify chirp owner
+	if chirp.UserID != user {
+		userErr := "User not allowed to restore chirp"
+		helperResponseError(w, http.StatusForbidden, userErr)
+		return
+	}
+	if time.Since(chirp.DeletedAt.Time) > cfg.restoreWindow {
+		windowErr := fmt.Sprintf("Chirps can only be restored within %v of deletion", cfg.restoreWindow)
+		helperResponseError(w, http.StatusGone, windowErr)
+		return
+	}
+	// a rechirp only comes back with its original
+	if chirp.Kind == chirpKindRechirp {
+		_, err = qtx.GetAChirp(r.Context(), chirp.ReferencedChirpID.UUID)
+		if errors.Is(err, sql.ErrNoRows) {
+			originalErr := "Cannot restore a rechirp of a deleted chirp"
+			helperResponseError(w, http.StatusConflict, originalErr)
+			return
+		}
+		if err != nil {
+			getAChirpErr := fmt.Sprintf("Error retrieving rechirped chirp: %v", err)
+			helperResponseError(w, http.StatusInternalServerError, getAChirpErr)
+			return
+		}
+	}
+
+	dbChirp, err := qtx.RestoreChirp(r.Context(), chirpID)
+	// the user may have rechirped the same chirp again since deleting this one
+	if helperIsUniqueViolation(err, "chirps_one_rechirp_per_user_idx") {
+		conflictErr := "Chirp already rechirped"
+		helperResponseError(w, http.StatusConflict, conflictErr)
+		return
+	}
+	if err != nil {
+		restoreErr := fmt.Sprintf("Error restoring chirp: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, restoreErr)
+		return
+	}
+	err = qtx.RestoreRechirpsOf(r.Context(), database.RestoreRechirpsOfParams{
+		ChirpID:   chirpID,
+		DeletedAt: chirp.DeletedAt,
+	})
+	if err != nil {
+		rechirpsErr := fmt.Sprintf("Error restoring rechirps: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, rechirpsErr)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		commitErr := fmt.Sprintf("Error committing chirp restore: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, commitErr)
+		return
+	}
+
+	restored, err := cfg.helperHydrateChirp(r.Context(), uuid.NullUUID{UUID: user, Valid: true}, dbChirp)
+	if err != nil {
+		hydrateErr := fmt.Sprintf("Error loading chirp details: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, hydrateErr)
+		return
+	}
+	helperResponseJSON(w, http.StatusOK, restored)
+}
+
+// runDeletedChirpPurger clears out tombstones older than deletedRetention
+// every purgeInterval until ctx is cancelled.
+func (cfg *apiConfig) runDeletedChirpPurger(ctx context.Context) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+	for {
+		if err := cfg.purgeDeletedChirps(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to purge deleted chirps: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeDeletedChirps removes expired tombstones outright. Ones that still
+// have replies keep their row so the thread holds together, but lose their
+// body, revisions and attachments.
+func (cfg *apiConfig) purgeDeletedChirps(ctx context.Context) error {
+	deletedBefore := time.Now().UTC().Add(-cfg.deletedRetention)
+	purged, err := cfg.db.PurgeDeletedChirps(ctx, deletedBefore)
+	if err != nil {
+		return err
+	}
+	cfg.helperDeleteBlobs(ctx, purged)
+
+	scrubbed, err := cfg.db.ScrubDeletedChirps(ctx, deletedBefore)
+	if err != nil {
+		return err
+	}
+	cfg.helperDeleteBlobs(ctx, scrubbed)
+	return nil
+}
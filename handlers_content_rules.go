@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seiobata/chirpy/internal/auth"
+	"github.com/seiobata/chirpy/internal/contentfilter"
+	"github.com/seiobata/chirpy/internal/database"
+)
+
+type ContentRule struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Pattern     string    `json:"pattern"`
+	MatchType   string    `json:"match_type"`
+	Action      string    `json:"action"`
+	Replacement string    `json:"replacement"`
+}
+
+type ContentFlag struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	RuleID    uuid.UUID `json:"rule_id"`
+	Pattern   string    `json:"pattern"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type contentRuleParameters struct {
+	Pattern     string `json:"pattern"`
+	MatchType   string `json:"match_type"`
+	Action      string `json:"action"`
+	Replacement string `json:"replacement"`
+}
+
+func helperContentRuleFromDB(dbRule database.ContentRule) ContentRule {
+	return ContentRule{
+		ID:          dbRule.ID,
+		CreatedAt:   dbRule.CreatedAt,
+		UpdatedAt:   dbRule.UpdatedAt,
+		Pattern:     dbRule.Pattern,
+		MatchType:   dbRule.MatchType,
+		Action:      dbRule.Action,
+		Replacement: dbRule.Replacement,
+	}
+}
+
+func helperValidateContentRule(params contentRuleParameters) error {
+	return contentfilter.Validate(contentfilter.Rule{
+		Pattern:     params.Pattern,
+		Match:       contentfilter.MatchType(params.MatchType),
+		Action:      contentfilter.Action(params.Action),
+		Replacement: params.Replacement,
+	})
+}
+
+// helperIsAdmin reports whether the request carries the admin API key. With
+// no key configured the content rule endpoints are closed to everyone.
+func (cfg *apiConfig) helperIsAdmin(r *http.Request) bool {
+	apiKey, err := auth.GetAPIKey(r.Header)
+	if err != nil || cfg.adminKey == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(apiKey), []byte(cfg.adminKey)) == 1
+}
+
+// helperSaveFlags records which flag rules a chirp matched, replacing any
+// flags from an earlier version of its text.
+func helperSaveFlags(ctx context.Context, q *database.Queries, chirpID uuid.UUID, ruleIDs []uuid.UUID) error {
+	err := q.DeleteChirpFlags(ctx, chirpID)
+	if err != nil {
+		return err
+	}
+	if len(ruleIDs) == 0 {
+		return nil
+	}
+	return q.AddChirpFlags(ctx, database.AddChirpFlagsParams{
+		ChirpID: chirpID,
+		RuleIds: ruleIDs,
+	})
+}
+
+func (cfg *apiConfig) handlerGetContentRules(w http.ResponseWriter, r *http.Request) {
+	if !cfg.helperIsAdmin(r) {
+		apiKeyErr := "Invalid API key"
+		helperResponseError(w, http.StatusUnauthorized, apiKeyErr)
+		return
+	}
+
+	dbRules, err := cfg.db.GetContentRules(r.Context())
+	if err != nil {
+		getRulesErr := fmt.Sprintf("Error retrieving content rules: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, getRulesErr)
+		return
+	}
+	rules := []ContentRule{}
+	for _, dbRule := range dbRules {
+		rules = append(rules, helperContentRuleFromDB(dbRule))
+	}
+	helperResponseJSON(w, http.StatusOK, rules)
+}
+
+func (cfg *apiConfig) handlerCreateContentRule(w http.ResponseWriter, r *http.Request) {
+	if !cfg.helperIsAdmin(r) {
+		apiKeyErr := "Invalid API key"
+		helperResponseError(w, http.StatusUnauthorized, apiKeyErr)
+		return
+	}
+
+	params := contentRuleParameters{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		decodeErr := fmt.Sprintf("Error decoding JSON: %v", err)
+		helperResponseError(w, http.StatusBadRequest, decodeErr)
+		return
+	}
+	err = helperValidateContentRule(params)
+	if err != nil {
+		ruleErr := fmt.Sprintf("Invalid content rule: %v", err)
+		helperResponseError(w, http.StatusBadRequest, ruleErr)
+		return
+	}
+
+	dbRule, err := cfg.db.CreateContentRule(r.Context(), database.CreateContentRuleParams{
+		Pattern:     params.Pattern,
+		MatchType:   params.MatchType,
+		Action:      params.Action,
+		Replacement: params.Replacement,
+	})
+	if err != nil {
+		createRuleErr := fmt.Sprintf("Error creating content rule: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, createRuleErr)
+		return
+	}
+	cfg.helperReloadContentFilter(r.Context())
+	helperResponseJSON(w, http.StatusCreated, helperContentRuleFromDB(dbRule))
+}
+
+func (cfg *apiConfig) handlerUpdateContentRule(w http.ResponseWriter, r *http.Request) {
+	if !cfg.helperIsAdmin(r) {
+		apiKeyErr := "Invalid API key"
+		helperResponseError(w, http.StatusUnauthorized, apiKeyErr)
+		return
+	}
+
+	ruleID, err := uuid.Parse(r.PathValue("ruleID"))
+	if err != nil {
+		idErr := fmt.Sprintf("Invalid ID: %v", err)
+		helperResponseError(w, http.StatusBadRequest, idErr)
+		return
+	}
+
+	params := contentRuleParameters{}
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&params)
+	if err != nil {
+		decodeErr := fmt.Sprintf("Error decoding JSON: %v", err)
+		helperResponseError(w, http.StatusBadRequest, decodeErr)
+		return
+	}
+	err = helperValidateContentRule(params)
+	if err != nil {
+		ruleErr := fmt.Sprintf("Invalid content rule: %v", err)
+		helperResponseError(w, http.StatusBadRequest, ruleErr)
+		return
+	}
+
+	dbRule, err := cfg.db.UpdateContentRule(r.Context(), database.UpdateContentRuleParams{
+		ID:          ruleID,
+		Pattern:     params.Pattern,
+		MatchType:   params.MatchType,
+		Action:      params.Action,
+		Replacement: params.Replacement,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		notFoundErr := "Content rule not found"
+		helperResponseError(w, http.StatusNotFound, notFoundErr)
+		return
+	}
+	if err != nil {
+		updateRuleErr := fmt.Sprintf("Error updating content rule: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, updateRuleErr)
+		return
+	}
+	cfg.helperReloadContentFilter(r.Context())
+	helperResponseJSON(w, http.StatusOK, helperContentRuleFromDB(dbRule))
+}
+
+func (cfg *apiConfig) handlerDeleteContentRule(w http.ResponseWriter, r *http.Request) {
+	if !cfg.helperIsAdmin(r) {
+		apiKeyErr := "Invalid API key"
+		helperResponseError(w, http.StatusUnauthorized, apiKeyErr)
+		return
+	}
+
+	ruleID, err := uuid.Parse(r.PathValue("ruleID"))
+	if err != nil {
+		idErr := fmt.Sprintf("Invalid ID: %v", err)
+		helperResponseError(w, http.StatusBadRequest, idErr)
+		return
+	}
+
+	deleted, err := cfg.db.DeleteContentRule(r.Context(), ruleID)
+	if err != nil {
+		deleteRuleErr := fmt.Sprintf("Error deleting content rule: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, deleteRuleErr)
+		return
+	}
+	if deleted == 0 {
+		notFoundErr := "Content rule not found"
+		helperResponseError(w, http.StatusNotFound, notFoundErr)
+		return
+	}
+	cfg.helperReloadContentFilter(r.Context())
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerGetContentFlags(w http.ResponseWriter, r *http.Request) {
+	if !cfg.helperIsAdmin(r) {
+		apiKeyErr := "Invalid API key"
+		helperResponseError(w, http.StatusUnauthorized, apiKeyErr)
+		return
+	}
+
+	limit, err := helperPageLimit(r)
+	if err != nil {
+		limitErr := fmt.Sprintf("Invalid limit: %v", err)
+		helperResponseError(w, http.StatusBadRequest, limitErr)
+		return
+	}
+
+	rows, err := cfg.db.GetChirpFlags(r.Context(), int32(limit))
+	if err != nil {
+		flagsErr := fmt.Sprintf("Error retrieving content flags: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, flagsErr)
+		return
+	}
+	flags := []ContentFlag{}
+	for _, row := range rows {
+		flags = append(flags, ContentFlag{
+			ChirpID:   row.ChirpID,
+			RuleID:    row.RuleID,
+			Pattern:   row.Pattern,
+			CreatedAt: row.CreatedAt,
+		})
+	}
+	helperResponseJSON(w, http.StatusOK, flags)
+}
+
+// helperReloadContentFilter applies rule changes to this instance straight
+// away. Failures are only logged since the refresher will try again.
+func (cfg *apiConfig) helperReloadContentFilter(ctx context.Context) {
+	if err := cfg.reloadContentFilter(ctx); err != nil {
+		log.Printf("Failed to reload content rules: %v", err)
+	}
+}
+
+// runContentFilterRefresher reloads the content rules every
+// contentRulesRefreshInterval until ctx is cancelled, picking up changes made
+// through other instances.
+func (cfg *apiConfig) runContentFilterRefresher(ctx context.Context) {
+	ticker := time.NewTicker(contentRulesRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := cfg.reloadContentFilter(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to reload content rules: %v", err)
+		}
+	}
+}
+
+func (cfg *apiConfig) reloadContentFilter(ctx context.Context) error {
+	dbRules, err := cfg.db.GetContentRules(ctx)
+	if err != nil {
+		return err
+	}
+	rules := make([]contentfilter.Rule, 0, len(dbRules))
+	for _, dbRule := range dbRules {
+		rules = append(rules, contentfilter.Rule{
+			ID:          dbRule.ID,
+			Pattern:     dbRule.Pattern,
+			Match:       contentfilter.MatchType(dbRule.MatchType),
+			Action:      contentfilter.Action(dbRule.Action),
+			Replacement: dbRule.Replacement,
+		})
+	}
+	return cfg.contentFilter.Reload(rules)
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seiobata/chirpy/internal/auth"
+	"github.com/seiobata/chirpy/internal/chirptext"
+	"github.com/seiobata/chirpy/internal/database"
+)
+
+type Draft struct {
+	ID            uuid.UUID     `json:"id"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+	UserID        uuid.UUID     `json:"user_id"`
+	Body          string        `json:"body"`
+	ParentChirpID uuid.NullUUID `json:"parent_chirp_id"`
+}
+
+func helperDraftFromDB(dbDraft database.Draft) Draft {
+	return Draft{
+		ID:            dbDraft.ID,
+		CreatedAt:     dbDraft.CreatedAt,
+		UpdatedAt:     dbDraft.UpdatedAt,
+		UserID:        dbDraft.UserID,
+		Body:          dbDraft.Body,
+		ParentChirpID: dbDraft.ParentChirpID,
+	}
+}
+
+// drafts are only checked against the chirp rules when they are published
+type draftParameters struct {
+	Body          string        `json:"body"`
+	ParentChirpID uuid.NullUUID `json:"parent_chirp_id"`
+}
+
+func (cfg *apiConfig) helperValidateDraft(ctx context.Context, params draftParameters) error {
+	if chirptext.Length(params.Body) > maxDraftLength {
+		return fmt.Errorf("draft is longer than %d characters", maxDraftLength)
+	}
+	// replies must point at a chirp that exists, as when published
+	if params.ParentChirpID.Valid {
+		_, err := cfg.db.GetAChirp(ctx, params.ParentChirpID.UUID)
+		if err != nil {
+			return fmt.Errorf("invalid parent chirp: %v", err)
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handlerCreateDraft(w http.ResponseWriter, r *http.Request) {
+	params := draftParameters{}
+
+	// validate token
+	user, err := cfg.helperAuthenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&params)
+	if err != nil {
+		decodeErr := fmt.Sprintf("Error decoding JSON: %v", err)
+		helperResponseError(w, http.StatusBadRequest, decodeErr)
+		return
+	}
+	err = cfg.helperValidateDraft(r.Context(), params)
+	if err != nil {
+		draftErr := fmt.Sprintf("Invalid draft: %v", err)
+		helperResponseError(w, http.StatusBadRequest, draftErr)
+		return
+	}
+
+	dbDraft, err := cfg.db.CreateDraft(r.Context(), database.CreateDraftParams{
+		UserID:        user,
+		Body:          params.Body,
+		ParentChirpID: params.ParentChirpID,
+	})
+	if err != nil {
+		createDraftErr := fmt.Sprintf("Error creating draft: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, createDraftErr)
+		return
+	}
+	helperResponseJSON(w, http.StatusCreated, helperDraftFromDB(dbDraft))
+}
+
+func (cfg *apiConfig) handlerGetDrafts(w http.ResponseWriter, r *http.Request) {
+	// validate token
+	user, err := cfg.helperAuthenticate(r, auth.ScopeChirpsRead)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	dbDrafts, err := cfg.db.GetDrafts(r.Context(), user)
+	if err != nil {
+		getDraftsErr := fmt.Sprintf("Error retrieving drafts: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, getDraftsErr)
+		return
+	}
+	drafts := []Draft{}
+	for _, dbDraft := range dbDrafts {
+		drafts = append(drafts, helperDraftFromDB(dbDraft))
+	}
+	helperResponseJSON(w, http.StatusOK, drafts)
+}
+
+func (cfg *apiConfig) handlerUpdateDraft(w http.ResponseWriter, r *http.Request) {
+	params := draftParameters{}
+
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		idErr := fmt.Sprintf("Invalid ID: %v", err)
+		helperResponseError(w, http.StatusBadRequest, idErr)
+		return
+	}
+
+	// validate token
+	user, err := cfg.helperAuthenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&params)
+	if err != nil {
+		decodeErr := fmt.Sprintf("Error decoding JSON: %v", err)
+		helperResponseError(w, http.StatusBadRequest, decodeErr)
+		return
+	}
+	err = cfg.helperValidateDraft(r.Context(), params)
+	if err != nil {
+		draftErr := fmt.Sprintf("Invalid draft: %v", err)
+		helperResponseError(w, http.StatusBadRequest, draftErr)
+		return
+	}
+
+	// another user's draft looks the same as a missing one
+	dbDraft, err := cfg.db.UpdateDraft(r.Context(), database.UpdateDraftParams{
+		ID:            draftID,
+		UserID:        user,
+		Body:          params.Body,
+		ParentChirpID: params.ParentChirpID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		notFoundErr := "Draft not found"
+		helperResponseError(w, http.StatusNotFound, notFoundErr)
+		return
+	}
+	if err != nil {
+		updateDraftErr := fmt.Sprintf("Error updating draft: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, updateDraftErr)
+		return
+	}
+	helperResponseJSON(w, http.StatusOK, helperDraftFromDB(dbDraft))
+}
+
+func (cfg *apiConfig) handlerDeleteDraft(w http.ResponseWriter, r *http.Request) {
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		idErr := fmt.Sprintf("Invalid ID: %v", err)
+		helperResponseError(w, http.StatusBadRequest, idErr)
+		return
+	}
+
+	// validate token
+	user, err := cfg.helperAuthenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	deleted, err := cfg.db.DeleteDraft(r.Context(), database.DeleteDraftParams{
+		ID:     draftID,
+		UserID: user,
+	})
+	if err != nil {
+		deleteDraftErr := fmt.Sprintf("Error deleting draft: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, deleteDraftErr)
+		return
+	}
+	if deleted == 0 {
+		notFoundErr := "Draft not found"
+		helperResponseError(w, http.StatusNotFound, notFoundErr)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerPublishDraft(w http.ResponseWriter, r *http.Request) {
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		idErr := fmt.Sprintf("Invalid ID: %v", err)
+		helperResponseError(w, http.StatusBadRequest, idErr)
+		return
+	}
+
+	// validate token
+	user, err := cfg.helperAuthenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	entitlements, err := cfg.helperEntitlements(r.Context(), user)
+	if err != nil {
+		userErr := fmt.Sprintf("Error retrieving user: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, userErr)
+		return
+	}
+
+	// the chirp is created and the draft removed together, so a draft can
+	// only ever be published once
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		txErr := fmt.Sprintf("Error starting transaction: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, txErr)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	draft, err := qtx.GetDraftForUpdate(r.Context(), database.GetDraftForUpdateParams{
+		ID:     draftID,
+		UserID: user,
+	})
+	if err != nil {
+		getDraftErr := fmt.Sprintf("Error retrieving draft: %v", err)
+		helperResponseError(w, http.StatusNotFound, getDraftErr)
+		return
+	}
+
+	validBody, flagged, err := cfg.helperValidateBody(draft.Body, entitlements.MaxChirpLength)
+	if err != nil {
+		validateBodyErr := fmt.Sprintf("Invalid chirp: %v", err)
+		helperResponseError(w, http.StatusBadRequest, validateBodyErr)
+		return
+	}
+	if draft.ParentChirpID.Valid {
+		_, err = qtx.GetAChirp(r.Context(), draft.ParentChirpID.UUID)
+		if err != nil {
+			parentErr := fmt.Sprintf("Invalid parent chirp: %v", err)
+			helperResponseError(w, http.StatusBadRequest, parentErr)
+			return
+		}
+	}
+
+	// only drafts that passed validation count against the rate limit
+	if !cfg.helperAllowChirp(w, user, entitlements) {
+		return
+	}
+
+	dbChirp, err := qtx.CreateChirp(r.Context(), database.CreateChirpParams{
+		Body:          validBody,
+		UserID:        user,
+		ParentChirpID: draft.ParentChirpID,
+		Kind:          chirpKindOriginal,
+		Published:     true,
+	})
+	if err != nil {
+		createChirpErr := fmt.Sprintf("Error creating chirp: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, createChirpErr)
+		return
+	}
+	err = helperSaveHashtags(r.Context(), qtx, dbChirp)
+	if err != nil {
+		hashtagsErr := fmt.Sprintf("Error saving hashtags: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, hashtagsErr)
+		return
+	}
+	err = helperSaveMentions(r.Context(), qtx, dbChirp)
+	if err != nil {
+		mentionsErr := fmt.Sprintf("Error saving mentions: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, mentionsErr)
+		return
+	}
+	err = helperSaveFlags(r.Context(), qtx, dbChirp.ID, flagged)
+	if err != nil {
+		flagsErr := fmt.Sprintf("Error saving content flags: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, flagsErr)
+		return
+	}
+	_, err = qtx.DeleteDraft(r.Context(), database.DeleteDraftParams{
+		ID:     draftID,
+		UserID: user,
+	})
+	if err != nil {
+		deleteDraftErr := fmt.Sprintf("Error deleting draft: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, deleteDraftErr)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		commitErr := fmt.Sprintf("Error committing published draft: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, commitErr)
+		return
+	}
+
+	chirp, err := cfg.helperHydrateChirp(r.Context(), uuid.NullUUID{UUID: user, Valid: true}, dbChirp)
+	if err != nil {
+		hydrateErr := fmt.Sprintf("Error loading chirp details: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, hydrateErr)
+		return
+	}
+	helperResponseJSON(w, http.StatusCreated, chirp)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/seiobata/chirpy/internal/auth"
+	"github.com/seiobata/chirpy/internal/database"
+)
+
+func (cfg *apiConfig) handlerFollowUser(w http.ResponseWriter, r *http.Request) {
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		idErr := fmt.Sprintf("Invalid ID: %v", err)
+		helperResponseError(w, http.StatusBadRequest, idErr)
+		return
+	}
+
+	// validate token
+	user, err := cfg.helperAuthenticate(r, auth.ScopeProfileWrite)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	if followeeID == user {
+		selfErr := "Users cannot follow themselves"
+		helperResponseError(w, http.StatusBadRequest, selfErr)
+		return
+	}
+	_, err = cfg.db.GetUserByID(r.Context(), followeeID)
+	if err != nil {
+		getUserErr := fmt.Sprintf("Error retrieving user: %v", err)
+		helperResponseError(w, http.StatusNotFound, getUserErr)
+		return
+	}
+
+	// following twice is a no-op
+	err = cfg.db.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: user,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		followErr := fmt.Sprintf("Error following user: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, followErr)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnfollowUser(w http.ResponseWriter, r *http.Request) {
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		idErr := fmt.Sprintf("Invalid ID: %v", err)
+		helperResponseError(w, http.StatusBadRequest, idErr)
+		return
+	}
+
+	// validate token
+	user, err := cfg.helperAuthenticate(r, auth.ScopeProfileWrite)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	err = cfg.db.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: user,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		unfollowErr := fmt.Sprintf("Error unfollowing user: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, unfollowErr)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerGetTimeline(w http.ResponseWriter, r *http.Request) {
+	// validate token
+	user, err := cfg.helperAuthenticate(r, auth.ScopeChirpsRead)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	limit, err := helperPageLimit(r)
+	if err != nil {
+		limitErr := fmt.Sprintf("Invalid limit: %v", err)
+		helperResponseError(w, http.StatusBadRequest, limitErr)
+		return
+	}
+
+	// resume after the cursor position if present
+	afterCreatedAt := sql.NullTime{}
+	afterID := uuid.NullUUID{}
+	cursor := r.URL.Query().Get("cursor")
+	if cursor != "" {
+		createdAt, id, err := helperDecodeCursor(cursor)
+		if err != nil {
+			cursorErr := fmt.Sprintf("Invalid cursor: %v", err)
+			helperResponseError(w, http.StatusBadRequest, cursorErr)
+			return
+		}
+		afterCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		afterID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	// the query reads at most limit+1 chirps per followed user, newest
+	// first, so its cost does not grow with each author's history
+	dbChirps, err := cfg.db.GetTimelinePage(r.Context(), database.GetTimelinePageParams{
+		AfterCreatedAt: afterCreatedAt,
+		AfterID:        afterID,
+		Limit:          int32(limit + 1),
+		FollowerID:     user,
+	})
+	if err != nil {
+		timelineErr := fmt.Sprintf("Error retrieving timeline: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, timelineErr)
+		return
+	}
+
+	page := ChirpPage{}
+	if len(dbChirps) > limit {
+		dbChirps = dbChirps[:limit]
+		last := dbChirps[len(dbChirps)-1]
+		page.NextCursor = helperEncodeCursor(last.CreatedAt, last.ID)
+	}
+	page.Chirps, err = cfg.helperHydrateChirps(r.Context(), uuid.NullUUID{UUID: user, Valid: true}, dbChirps)
+	if err != nil {
+		hydrateErr := fmt.Sprintf("Error loading chirp details: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, hydrateErr)
+		return
+	}
+	helperResponseJSON(w, http.StatusOK, page)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seiobata/chirpy/internal/database"
+	"github.com/seiobata/chirpy/internal/entities"
+)
+
+type TrendingHashtag struct {
+	Tag   string  `json:"tag"`
+	Score float64 `json:"score"`
+	Uses  int64   `json:"uses"`
+}
+
+// helperSaveHashtags indexes the hashtags in a chirp's body, replacing any
+// previously indexed for it. Pass a transaction-bound Queries so the chirp
+// and its hashtags are written together.
+func helperSaveHashtags(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	err := q.DeleteChirpHashtags(ctx, chirp.ID)
+	if err != nil {
+		return err
+	}
+	for _, tag := range entities.ExtractHashtags(chirp.Body) {
+		hashtag, err := q.UpsertHashtag(ctx, tag)
+		if err != nil {
+			return err
+		}
+		err = q.AddChirpHashtag(ctx, database.AddChirpHashtagParams{
+			ChirpID:   chirp.ID,
+			HashtagID: hashtag.ID,
+			CreatedAt: chirp.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handlerGetHashtagChirps(w http.ResponseWriter, r *http.Request) {
+	tag := strings.ToLower(strings.TrimPrefix(r.PathValue("tag"), "#"))
+
+	// personalize the response when a token is supplied
+	viewer, err := cfg.helperOptionalUser(r)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	limit, err := helperPageLimit(r)
+	if err != nil {
+		limitErr := fmt.Sprintf("Invalid limit: %v", err)
+		helperResponseError(w, http.StatusBadRequest, limitErr)
+		return
+	}
+
+	// resume after the cursor position if present
+	afterCreatedAt := sql.NullTime{}
+	afterID := uuid.NullUUID{}
+	cursor := r.URL.Query().Get("cursor")
+	if cursor != "" {
+		createdAt, id, err := helperDecodeCursor(cursor)
+		if err != nil {
+			cursorErr := fmt.Sprintf("Invalid cursor: %v", err)
+			helperResponseError(w, http.StatusBadRequest, cursorErr)
+			return
+		}
+		afterCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		afterID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	dbChirps, err := cfg.db.GetHashtagChirpsPage(r.Context(), database.GetHashtagChirpsPageParams{
+		Tag:            tag,
+		AfterCreatedAt: afterCreatedAt,
+		AfterID:        afterID,
+		Limit:          int32(limit + 1),
+	})
+	if err != nil {
+		hashtagErr := fmt.Sprintf("Error retrieving hashtag chirps: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, hashtagErr)
+		return
+	}
+
+	page := ChirpPage{}
+	if len(dbChirps) > limit {
+		dbChirps = dbChirps[:limit]
+		last := dbChirps[len(dbChirps)-1]
+		page.NextCursor = helperEncodeCursor(last.CreatedAt, last.ID)
+	}
+	page.Chirps, err = cfg.helperHydrateChirps(r.Context(), viewer, dbChirps)
+	if err != nil {
+		hydrateErr := fmt.Sprintf("Error loading chirp details: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, hydrateErr)
+		return
+	}
+	helperResponseJSON(w, http.StatusOK, page)
+}
+
+func (cfg *apiConfig) handlerGetTrending(w http.ResponseWriter, r *http.Request) {
+	// served from the snapshot kept fresh by runTrendingRefresher
+	trending := []TrendingHashtag{}
+	if snapshot := cfg.trending.Load(); snapshot != nil {
+		trending = *snapshot
+	}
+	helperResponseJSON(w, http.StatusOK, trending)
+}
+
+// runTrendingRefresher recomputes the trending hashtags every
+// trendingRefreshInterval until ctx is cancelled.
+func (cfg *apiConfig) runTrendingRefresher(ctx context.Context) {
+	ticker := time.NewTicker(trendingRefreshInterval)
+	defer ticker.Stop()
+	for {
+		if err := cfg.refreshTrending(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to refresh trending hashtags: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshTrending scores each tag used within trendingWindow, where every use
+// counts half as much for each trendingHalfLife that has passed since.
+func (cfg *apiConfig) refreshTrending(ctx context.Context) error {
+	rows, err := cfg.db.GetTrendingHashtags(ctx, database.GetTrendingHashtagsParams{
+		HalfLifeSeconds: trendingHalfLife.Seconds(),
+		WindowSeconds:   trendingWindow.Seconds(),
+		Limit:           maxTrendingHashtags,
+	})
+	if err != nil {
+		return err
+	}
+	trending := make([]TrendingHashtag, 0, len(rows))
+	for _, row := range rows {
+		trending = append(trending, TrendingHashtag{
+			Tag:   row.Tag,
+			Score: row.Score,
+			Uses:  row.Uses,
+		})
+	}
+	cfg.trending.Store(&trending)
+	return nil
+}
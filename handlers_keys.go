@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seiobata/chirpy/internal/auth"
+	"github.com/seiobata/chirpy/internal/database"
+)
+
+// loginScopes are carried by access tokens from a login or refresh.
+var loginScopes = []string{
+	auth.ScopeChirpsRead,
+	auth.ScopeChirpsWrite,
+	auth.ScopeProfileWrite,
+	auth.ScopeAccount,
+}
+
+// apiKeyScopes are the scopes a user may grant an API key.
+var apiKeyScopes = []string{
+	auth.ScopeChirpsRead,
+	auth.ScopeChirpsWrite,
+	auth.ScopeProfileWrite,
+}
+
+// APIKey describes a personal API key. The key itself is only returned when
+// it is created.
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+func helperAPIKeyFromDB(dbKey database.ApiKey) APIKey {
+	key := APIKey{
+		ID:        dbKey.ID,
+		CreatedAt: dbKey.CreatedAt,
+		Name:      dbKey.Name,
+		Prefix:    dbKey.KeyPrefix,
+		Scopes:    dbKey.Scopes,
+		ExpiresAt: dbKey.ExpiresAt,
+	}
+	if dbKey.LastUsedAt.Valid {
+		key.LastUsedAt = &dbKey.LastUsedAt.Time
+	}
+	return key
+}
+
+// helperValidateScopes checks requested API key scopes and returns them
+// without duplicates.
+func helperValidateScopes(requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+	scopes := []string{}
+	for _, scope := range requested {
+		if !slices.Contains(apiKeyScopes, scope) {
+			return nil, fmt.Errorf("scope %q can't be granted to an API key", scope)
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+func (cfg *apiConfig) handlerCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name      string    `json:"name"`
+		Scopes    []string  `json:"scopes"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	type response struct {
+		APIKey
+		Key string `json:"key"`
+	}
+
+	// validate token
+	user, err := cfg.helperAuthenticate(r, auth.ScopeAccount)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&params)
+	if err != nil {
+		decodeErr := fmt.Sprintf("Error decoding JSON: %v", err)
+		helperResponseError(w, http.StatusBadRequest, decodeErr)
+		return
+	}
+
+	nameLength := len([]rune(params.Name))
+	if nameLength == 0 || nameLength > maxAPIKeyNameLength {
+		nameErr := fmt.Sprintf("Name must be 1 to %d characters", maxAPIKeyNameLength)
+		helperResponseError(w, http.StatusBadRequest, nameErr)
+		return
+	}
+	scopes, err := helperValidateScopes(params.Scopes)
+	if err != nil {
+		scopesErr := fmt.Sprintf("Invalid scopes: %v", err)
+		helperResponseError(w, http.StatusBadRequest, scopesErr)
+		return
+	}
+	now := time.Now().UTC()
+	if !params.ExpiresAt.After(now) || params.ExpiresAt.After(now.Add(maxAPIKeyLifetime)) {
+		expiryErr := "Expiry must be in the future and within a year"
+		helperResponseError(w, http.StatusBadRequest, expiryErr)
+		return
+	}
+
+	key, err := auth.MakeAPIKey()
+	if err != nil {
+		makeKeyErr := fmt.Sprintf("Error generating API key: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, makeKeyErr)
+		return
+	}
+
+	// lock the user row so concurrent creates can't both pass the count
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		txErr := fmt.Sprintf("Error starting transaction: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, txErr)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	if _, err := qtx.GetUserForUpdate(r.Context(), user); err != nil {
+		getUserErr := fmt.Sprintf("Error retrieving user: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, getUserErr)
+		return
+	}
+	count, err := qtx.CountAPIKeys(r.Context(), user)
+	if err != nil {
+		countErr := fmt.Sprintf("Error counting API keys: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, countErr)
+		return
+	}
+	if count >= maxAPIKeysPerUser {
+		limitErr := fmt.Sprintf("Users can have at most %d API keys", maxAPIKeysPerUser)
+		helperResponseError(w, http.StatusConflict, limitErr)
+		return
+	}
+
+	dbKey, err := qtx.CreateAPIKey(r.Context(), database.CreateAPIKeyParams{
+		UserID:    user,
+		Name:      params.Name,
+		KeyPrefix: auth.APIKeyDisplayPrefix(key),
+		KeyHash:   auth.HashAPIKey(key),
+		Scopes:    scopes,
+		ExpiresAt: params.ExpiresAt.UTC(),
+	})
+	if err != nil {
+		createKeyErr := fmt.Sprintf("Error creating API key: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, createKeyErr)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		commitErr := fmt.Sprintf("Error committing API key: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, commitErr)
+		return
+	}
+
+	// the key can't be recovered from its hash, so this is the only time
+	// the user sees it
+	helperResponseJSON(w, http.StatusCreated, response{
+		APIKey: helperAPIKeyFromDB(dbKey),
+		Key:    key,
+	})
+}
+
+func (cfg *apiConfig) handlerGetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	// validate token
+	user, err := cfg.helperAuthenticate(r, auth.ScopeAccount)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	dbKeys, err := cfg.db.GetAPIKeys(r.Context(), user)
+	if err != nil {
+		getKeysErr := fmt.Sprintf("Error retrieving API keys: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, getKeysErr)
+		return
+	}
+	keys := []APIKey{}
+	for _, dbKey := range dbKeys {
+		keys = append(keys, helperAPIKeyFromDB(dbKey))
+	}
+	helperResponseJSON(w, http.StatusOK, keys)
+}
+
+func (cfg *apiConfig) handlerDeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	keyID, err := uuid.Parse(r.PathValue("keyID"))
+	if err != nil {
+		idErr := fmt.Sprintf("Invalid ID: %v", err)
+		helperResponseError(w, http.StatusBadRequest, idErr)
+		return
+	}
+
+	// validate token
+	user, err := cfg.helperAuthenticate(r, auth.ScopeAccount)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	// other users' keys look the same as ones that don't exist; a deleted
+	// key stops working at once
+	deleted, err := cfg.db.DeleteAPIKey(r.Context(), database.DeleteAPIKeyParams{
+		ID:     keyID,
+		UserID: user,
+	})
+	if err != nil {
+		deleteKeyErr := fmt.Sprintf("Error deleting API key: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, deleteKeyErr)
+		return
+	}
+	if deleted == 0 {
+		notFoundErr := "API key not found"
+		helperResponseError(w, http.StatusNotFound, notFoundErr)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/seiobata/chirpy/internal/auth"
+	"github.com/seiobata/chirpy/internal/database"
+)
+
+func (cfg *apiConfig) handlerLikeChirp(w http.ResponseWriter, r *http.Request) {
+	cfg.helperSetChirpLike(w, r, true)
+}
+
+func (cfg *apiConfig) handlerUnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	cfg.helperSetChirpLike(w, r, false)
+}
+
+// helperSetChirpLike adds or removes the caller's like. The like row and the
+// chirp's like_count change in a single statement, so concurrent requests
+// cannot lose updates, and repeating a request leaves the count unchanged.
+func (cfg *apiConfig) helperSetChirpLike(w http.ResponseWriter, r *http.Request, like bool) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		idErr := fmt.Sprintf("Invalid ID: %v", err)
+		helperResponseError(w, http.StatusBadRequest, idErr)
+		return
+	}
+
+	// check token
+	validID, err := cfg.helperAuthenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	_, err = cfg.db.GetAChirp(r.Context(), chirpID)
+	if err != nil {
+		getAChirpErr := fmt.Sprintf("Error retrieving chirp: %v", err)
+		helperResponseError(w, http.StatusNotFound, getAChirpErr)
+		return
+	}
+
+	likeParams := database.LikeChirpParams{
+		UserID:  validID,
+		ChirpID: chirpID,
+	}
+	if like {
+		_, err = cfg.db.LikeChirp(r.Context(), likeParams)
+	} else {
+		_, err = cfg.db.UnlikeChirp(r.Context(), database.UnlikeChirpParams(likeParams))
+	}
+	if err != nil {
+		likeErr := fmt.Sprintf("Error updating like: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, likeErr)
+		return
+	}
+
+	// return the chirp with its fresh like count
+	dbChirp, err := cfg.db.GetAChirp(r.Context(), chirpID)
+	if err != nil {
+		getAChirpErr := fmt.Sprintf("Error retrieving chirp: %v", err)
+		helperResponseError(w, http.StatusNotFound, getAChirpErr)
+		return
+	}
+	chirp, err := cfg.helperHydrateChirp(r.Context(), uuid.NullUUID{UUID: validID, Valid: true}, dbChirp)
+	if err != nil {
+		hydrateErr := fmt.Sprintf("Error loading chirp details: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, hydrateErr)
+		return
+	}
+	helperResponseJSON(w, http.StatusOK, chirp)
+}
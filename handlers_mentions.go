@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/seiobata/chirpy/internal/auth"
+	"github.com/seiobata/chirpy/internal/database"
+	"github.com/seiobata/chirpy/internal/entities"
+)
+
+// helperSaveMentions records the users @mentioned in a chirp's body,
+// replacing any previously recorded for it. Unknown handles are ignored.
+func helperSaveMentions(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	err := q.DeleteChirpMentions(ctx, chirp.ID)
+	if err != nil {
+		return err
+	}
+	handles := entities.ExtractMentions(chirp.Body)
+	if len(handles) == 0 {
+		return nil
+	}
+	return q.AddMentions(ctx, database.AddMentionsParams{
+		ChirpID:   chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		Handles:   handles,
+	})
+}
+
+func (cfg *apiConfig) handlerGetMyMentions(w http.ResponseWriter, r *http.Request) {
+	// validate token
+	user, err := cfg.helperAuthenticate(r, auth.ScopeChirpsRead)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	limit, err := helperPageLimit(r)
+	if err != nil {
+		limitErr := fmt.Sprintf("Invalid limit: %v", err)
+		helperResponseError(w, http.StatusBadRequest, limitErr)
+		return
+	}
+
+	// resume after the cursor position if present
+	afterCreatedAt := sql.NullTime{}
+	afterID := uuid.NullUUID{}
+	cursor := r.URL.Query().Get("cursor")
+	if cursor != "" {
+		createdAt, id, err := helperDecodeCursor(cursor)
+		if err != nil {
+			cursorErr := fmt.Sprintf("Invalid cursor: %v", err)
+			helperResponseError(w, http.StatusBadRequest, cursorErr)
+			return
+		}
+		afterCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		afterID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	dbChirps, err := cfg.db.GetMentionsPage(r.Context(), database.GetMentionsPageParams{
+		UserID:         user,
+		AfterCreatedAt: afterCreatedAt,
+		AfterID:        afterID,
+		Limit:          int32(limit + 1),
+	})
+	if err != nil {
+		mentionsErr := fmt.Sprintf("Error retrieving mentions: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, mentionsErr)
+		return
+	}
+
+	page := ChirpPage{}
+	if len(dbChirps) > limit {
+		dbChirps = dbChirps[:limit]
+		last := dbChirps[len(dbChirps)-1]
+		page.NextCursor = helperEncodeCursor(last.CreatedAt, last.ID)
+	}
+	page.Chirps, err = cfg.helperHydrateChirps(r.Context(), uuid.NullUUID{UUID: user, Valid: true}, dbChirps)
+	if err != nil {
+		hydrateErr := fmt.Sprintf("Error loading chirp details: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, hydrateErr)
+		return
+	}
+	helperResponseJSON(w, http.StatusOK, page)
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seiobata/chirpy/internal/auth"
+	"github.com/seiobata/chirpy/internal/database"
+)
+
+// Polka webhook events
+const (
+	polkaUserUpgraded        = "user.upgraded"
+	polkaUserDowngraded      = "user.downgraded"
+	polkaSubscriptionRenewed = "subscription.renewed"
+	polkaSubscriptionExpired = "subscription.expired"
+)
+
+// helperRedExpiry works out when a membership granted by event ends. Polka
+// may send the date; otherwise the membership runs for chirpyRedTerm, and a
+// renewal or repeated upgrade never cuts short time already paid for.
+func helperRedExpiry(dbUser database.User, event string, sent *time.Time) sql.NullTime {
+	if sent != nil {
+		return sql.NullTime{Time: sent.UTC(), Valid: true}
+	}
+	now := time.Now().UTC()
+	current := now
+	if dbUser.IsChirpyRed && dbUser.RedExpiresAt.Valid && dbUser.RedExpiresAt.Time.After(now) {
+		current = dbUser.RedExpiresAt.Time
+	}
+	expires := now.Add(chirpyRedTerm)
+	if event == polkaSubscriptionRenewed {
+		expires = current.Add(chirpyRedTerm)
+	} else if current.After(expires) {
+		expires = current
+	}
+	return sql.NullTime{Time: expires, Valid: true}
+}
+
+func (cfg *apiConfig) handlerUpgradeUserToRed(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		ID        string     `json:"id"`
+		Event     string     `json:"event"`
+		CreatedAt *time.Time `json:"created_at"`
+		Data      struct {
+			UserID    string     `json:"user_id"`
+			ExpiresAt *time.Time `json:"expires_at"`
+		} `json:"data"`
+	}
+
+	// the signature covers the raw body, so read it before decoding
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBytes))
+	if err != nil {
+		readErr := fmt.Sprintf("Error reading body: %v", err)
+		helperResponseError(w, http.StatusBadRequest, readErr)
+		return
+	}
+
+	// check the Polka signature and reject stale or replayed timestamps
+	err = auth.VerifyWebhook(
+		cfg.polkaSecrets,
+		r.Header.Get("X-Polka-Timestamp"),
+		r.Header.Get("X-Polka-Signature"),
+		body,
+		time.Now(),
+		polkaSignatureTolerance,
+	)
+	if err != nil {
+		signatureErr := "Invalid signature"
+		helperResponseError(w, http.StatusUnauthorized, signatureErr)
+		return
+	}
+
+	params := parameters{}
+	err = json.Unmarshal(body, &params)
+	if err != nil {
+		decodeErr := fmt.Sprintf("Error decoding JSON: %v", err)
+		helperResponseError(w, http.StatusBadRequest, decodeErr)
+		return
+	}
+	if params.ID == "" {
+		idErr := "Event ID is missing"
+		helperResponseError(w, http.StatusBadRequest, idErr)
+		return
+	}
+
+	// get user ID
+	user, err := uuid.Parse(params.Data.UserID)
+	if err != nil {
+		idErr := fmt.Sprintf("Error parsing ID: %v", err)
+		helperResponseError(w, http.StatusBadRequest, idErr)
+		return
+	}
+
+	// events are ordered by when Polka says they happened, falling back to
+	// when it signed the delivery
+	eventAt := time.Time{}
+	if params.CreatedAt != nil {
+		eventAt = params.CreatedAt.UTC()
+	} else {
+		unix, _ := strconv.ParseInt(r.Header.Get("X-Polka-Timestamp"), 10, 64)
+		eventAt = time.Unix(unix, 0).UTC()
+	}
+
+	// acknowledge events we don't act on, including grants that have
+	// already lapsed; ending events carry past expiry dates by design
+	isChirpyRed := false
+	switch params.Event {
+	case polkaUserUpgraded, polkaSubscriptionRenewed:
+		if params.Data.ExpiresAt != nil && !params.Data.ExpiresAt.After(time.Now()) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		isChirpyRed = true
+	case polkaUserDowngraded, polkaSubscriptionExpired:
+	default:
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// the membership and its history entry change together
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		txErr := fmt.Sprintf("Error starting transaction: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, txErr)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// a redelivered event was handled the first time round
+	marked, err := qtx.MarkPolkaEventProcessed(r.Context(), database.MarkPolkaEventProcessedParams{
+		ID:    params.ID,
+		Event: params.Event,
+	})
+	if err != nil {
+		markErr := fmt.Sprintf("Error recording event: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, markErr)
+		return
+	}
+	if marked == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	dbUser, err := qtx.GetUserForUpdate(r.Context(), user)
+	if errors.Is(err, sql.ErrNoRows) {
+		notFoundErr := "User not found"
+		helperResponseError(w, http.StatusNotFound, notFoundErr)
+		return
+	}
+	if err != nil {
+		getUserErr := fmt.Sprintf("Error retrieving user: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, getUserErr)
+		return
+	}
+
+	// an event that arrives after a newer one for the same user is stale;
+	// the user row lock keeps this check and the change together
+	latest, err := qtx.GetLatestChirpyRedEventAt(r.Context(), user)
+	if err != nil {
+		historyErr := fmt.Sprintf("Error retrieving membership history: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, historyErr)
+		return
+	}
+	if latest.Valid && eventAt.Before(latest.Time) {
+		if err := tx.Commit(); err != nil {
+			commitErr := fmt.Sprintf("Error committing event: %v", err)
+			helperResponseError(w, http.StatusInternalServerError, commitErr)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// the user's plan entitlements follow from their membership
+	expiresAt := sql.NullTime{}
+	if isChirpyRed {
+		expiresAt = helperRedExpiry(dbUser, params.Event, params.Data.ExpiresAt)
+	}
+	_, err = qtx.SetChirpyRed(r.Context(), database.SetChirpyRedParams{
+		ID:           user,
+		IsChirpyRed:  isChirpyRed,
+		RedExpiresAt: expiresAt,
+	})
+	if err != nil {
+		isChirpyRedErr := fmt.Sprintf("Error updating user: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, isChirpyRedErr)
+		return
+	}
+	err = qtx.RecordChirpyRedChange(r.Context(), database.RecordChirpyRedChangeParams{
+		UserID:      user,
+		Event:       params.Event,
+		IsChirpyRed: isChirpyRed,
+		ExpiresAt:   expiresAt,
+		EventAt:     sql.NullTime{Time: eventAt, Valid: true},
+	})
+	if err != nil {
+		historyErr := fmt.Sprintf("Error recording membership change: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, historyErr)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		commitErr := fmt.Sprintf("Error committing membership change: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, commitErr)
+		return
+	}
+
+	// successful request; return 'no content' header
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// runChirpyRedExpirySweeper ends lapsed Chirpy Red memberships every
+// chirpyRedSweepInterval until ctx is cancelled, in case Polka never sends
+// the expiry event.
+func (cfg *apiConfig) runChirpyRedExpirySweeper(ctx context.Context) {
+	ticker := time.NewTicker(chirpyRedSweepInterval)
+	defer ticker.Stop()
+	for {
+		if err := cfg.expireChirpyRed(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to expire Chirpy Red memberships: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// expireChirpyRed ends every membership past its expiry, recording each in
+// the membership history.
+func (cfg *apiConfig) expireChirpyRed(ctx context.Context) error {
+	expired, err := cfg.db.ExpireChirpyRed(ctx, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if len(expired) > 0 {
+		log.Printf("Expired %d Chirpy Red memberships", len(expired))
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seiobata/chirpy/internal/auth"
+	"github.com/seiobata/chirpy/internal/chirptext"
+	"github.com/seiobata/chirpy/internal/database"
+)
+
+// Poll is the state of a chirp's poll as seen by one viewer. Vote counts are
+// left out until the viewer has voted or the poll has closed.
+type Poll struct {
+	ClosesAt      time.Time     `json:"closes_at"`
+	Closed        bool          `json:"closed"`
+	Voted         bool          `json:"voted"`
+	VotedOptionID uuid.NullUUID `json:"voted_option_id"`
+	TotalVotes    *int32        `json:"total_votes,omitempty"`
+	Options       []PollOption  `json:"options"`
+}
+
+type PollOption struct {
+	ID    uuid.UUID `json:"id"`
+	Text  string    `json:"text"`
+	Votes *int32    `json:"votes,omitempty"`
+}
+
+type pollParameters struct {
+	Options  []string  `json:"options"`
+	ClosesAt time.Time `json:"closes_at"`
+}
+
+// helperValidatePoll checks a new poll and returns its trimmed options. The
+// poll opens when its chirp is published, at opensAt.
+func helperValidatePoll(params pollParameters, opensAt time.Time) ([]string, error) {
+	if len(params.Options) < minPollOptions || len(params.Options) > maxPollOptions {
+		return nil, fmt.Errorf("must have between %d and %d options", minPollOptions, maxPollOptions)
+	}
+	options := make([]string, 0, len(params.Options))
+	seen := map[string]bool{}
+	for _, option := range params.Options {
+		option = chirptext.Normalize(strings.TrimSpace(option))
+		if option == "" {
+			return nil, errors.New("options cannot be empty")
+		}
+		if chirptext.Length(option) > maxPollOptionLength {
+			return nil, fmt.Errorf("options cannot be longer than %d characters", maxPollOptionLength)
+		}
+		key := strings.ToLower(option)
+		if seen[key] {
+			return nil, errors.New("options must be distinct")
+		}
+		seen[key] = true
+		options = append(options, option)
+	}
+	if err := helperValidatePollClose(params.ClosesAt, opensAt); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+// helperValidatePollClose checks that a poll opening at opensAt closes within
+// the allowed duration. Rescheduling a chirp checks its poll again.
+func helperValidatePollClose(closesAt, opensAt time.Time) error {
+	if !closesAt.After(opensAt) {
+		return errors.New("closes_at must be after the chirp is published")
+	}
+	if closesAt.After(opensAt.Add(maxPollDuration)) {
+		return fmt.Errorf("closes_at must be within %v of publishing", maxPollDuration)
+	}
+	return nil
+}
+
+// helperSavePoll stores a validated poll for a chirp.
+func helperSavePoll(ctx context.Context, q *database.Queries, chirpID uuid.UUID, options []string, closesAt time.Time) error {
+	err := q.CreatePoll(ctx, database.CreatePollParams{
+		ChirpID:  chirpID,
+		ClosesAt: closesAt.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	for i, option := range options {
+		err = q.AddPollOption(ctx, database.AddPollOptionParams{
+			ChirpID:  chirpID,
+			Position: int32(i),
+			Text:     option,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// helperApplyPolls fills in the poll on each chirp that has one.
+func (cfg *apiConfig) helperApplyPolls(ctx context.Context, viewer uuid.NullUUID, chirps []Chirp) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+	chirpIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.ID)
+	}
+
+	dbPolls, err := cfg.db.GetPollsForChirps(ctx, chirpIDs)
+	if err != nil {
+		return err
+	}
+	if len(dbPolls) == 0 {
+		return nil
+	}
+	dbOptions, err := cfg.db.GetPollOptionsForChirps(ctx, chirpIDs)
+	if err != nil {
+		return err
+	}
+	votes := map[uuid.UUID]uuid.UUID{}
+	if viewer.Valid {
+		dbVotes, err := cfg.db.GetPollVotesByUser(ctx, database.GetPollVotesByUserParams{
+			UserID:   viewer.UUID,
+			ChirpIds: chirpIDs,
+		})
+		if err != nil {
+			return err
+		}
+		for _, dbVote := range dbVotes {
+			votes[dbVote.ChirpID] = dbVote.OptionID
+		}
+	}
+
+	optionsByChirp := map[uuid.UUID][]database.PollOption{}
+	for _, dbOption := range dbOptions {
+		optionsByChirp[dbOption.ChirpID] = append(optionsByChirp[dbOption.ChirpID], dbOption)
+	}
+	polls := map[uuid.UUID]*Poll{}
+	now := time.Now()
+	for _, dbPoll := range dbPolls {
+		optionID, voted := votes[dbPoll.ChirpID]
+		poll := &Poll{
+			ClosesAt: dbPoll.ClosesAt,
+			Closed:   !now.Before(dbPoll.ClosesAt),
+			Voted:    voted,
+			Options:  []PollOption{},
+		}
+		if voted {
+			poll.VotedOptionID = uuid.NullUUID{UUID: optionID, Valid: true}
+		}
+		showResults := poll.Voted || poll.Closed
+		total := int32(0)
+		for _, dbOption := range optionsByChirp[dbPoll.ChirpID] {
+			option := PollOption{
+				ID:   dbOption.ID,
+				Text: dbOption.Text,
+			}
+			if showResults {
+				option.Votes = &dbOption.VoteCount
+				total += dbOption.VoteCount
+			}
+			poll.Options = append(poll.Options, option)
+		}
+		if showResults {
+			poll.TotalVotes = &total
+		}
+		polls[dbPoll.ChirpID] = poll
+	}
+	for i := range chirps {
+		if chirps[i].Deleted {
+			continue
+		}
+		chirps[i].Poll = polls[chirps[i].ID]
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handlerVotePoll(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		OptionID uuid.UUID `json:"option_id"`
+	}
+	params := parameters{}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		idErr := fmt.Sprintf("Invalid ID: %v", err)
+		helperResponseError(w, http.StatusBadRequest, idErr)
+		return
+	}
+
+	// validate token
+	user, err := cfg.helperAuthenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&params)
+	if err != nil {
+		decodeErr := fmt.Sprintf("Error decoding JSON: %v", err)
+		helperResponseError(w, http.StatusBadRequest, decodeErr)
+		return
+	}
+
+	dbChirp, err := cfg.db.GetAChirp(r.Context(), chirpID)
+	if err != nil {
+		getAChirpErr := fmt.Sprintf("Error retrieving chirp: %v", err)
+		helperResponseError(w, http.StatusNotFound, getAChirpErr)
+		return
+	}
+	poll, err := cfg.db.GetPoll(r.Context(), chirpID)
+	if err != nil {
+		pollErr := fmt.Sprintf("Error retrieving poll: %v", err)
+		helperResponseError(w, http.StatusNotFound, pollErr)
+		return
+	}
+	if !time.Now().Before(poll.ClosesAt) {
+		closedErr := "Poll is closed"
+		helperResponseError(w, http.StatusConflict, closedErr)
+		return
+	}
+	_, err = cfg.db.GetPollOption(r.Context(), database.GetPollOptionParams{
+		ID:      params.OptionID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		optionErr := fmt.Sprintf("Invalid poll option: %v", err)
+		helperResponseError(w, http.StatusBadRequest, optionErr)
+		return
+	}
+
+	// the vote and its count are recorded together; a second vote changes nothing
+	voted, err := cfg.db.CastPollVote(r.Context(), database.CastPollVoteParams{
+		ChirpID:  chirpID,
+		UserID:   user,
+		OptionID: params.OptionID,
+	})
+	if err != nil {
+		voteErr := fmt.Sprintf("Error recording vote: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, voteErr)
+		return
+	}
+	if voted == 0 {
+		conflictErr := "User already voted in this poll"
+		helperResponseError(w, http.StatusConflict, conflictErr)
+		return
+	}
+
+	chirp, err := cfg.helperHydrateChirp(r.Context(), uuid.NullUUID{UUID: user, Valid: true}, dbChirp)
+	if err != nil {
+		hydrateErr := fmt.Sprintf("Error loading chirp details: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, hydrateErr)
+		return
+	}
+	helperResponseJSON(w, http.StatusOK, chirp)
+}
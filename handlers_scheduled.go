@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seiobata/chirpy/internal/auth"
+	"github.com/seiobata/chirpy/internal/database"
+)
+
+// helperValidatePublishAt checks that a scheduled chirp goes out in the
+// future but no further ahead than maxScheduleAhead.
+func helperValidatePublishAt(publishAt time.Time) error {
+	now := time.Now()
+	if !publishAt.After(now) {
+		return errors.New("must be in the future")
+	}
+	if publishAt.After(now.Add(maxScheduleAhead)) {
+		return fmt.Errorf("must be within %v", maxScheduleAhead)
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handlerGetScheduledChirps(w http.ResponseWriter, r *http.Request) {
+	// validate token
+	user, err := cfg.helperAuthenticate(r, auth.ScopeChirpsRead)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	dbChirps, err := cfg.db.GetScheduledChirps(r.Context(), user)
+	if err != nil {
+		getChirpsErr := fmt.Sprintf("Error retrieving scheduled chirps: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, getChirpsErr)
+		return
+	}
+	chirps, err := cfg.helperHydrateChirps(r.Context(), uuid.NullUUID{UUID: user, Valid: true}, dbChirps)
+	if err != nil {
+		hydrateErr := fmt.Sprintf("Error loading chirp details: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, hydrateErr)
+		return
+	}
+	helperResponseJSON(w, http.StatusOK, chirps)
+}
+
+func (cfg *apiConfig) handlerRescheduleChirp(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		PublishAt time.Time `json:"publish_at"`
+	}
+	params := parameters{}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		idErr := fmt.Sprintf("Invalid ID: %v", err)
+		helperResponseError(w, http.StatusBadRequest, idErr)
+		return
+	}
+
+	// validate token
+	user, err := cfg.helperAuthenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&params)
+	if err != nil {
+		decodeErr := fmt.Sprintf("Error decoding JSON: %v", err)
+		helperResponseError(w, http.StatusBadRequest, decodeErr)
+		return
+	}
+	err = helperValidatePublishAt(params.PublishAt)
+	if err != nil {
+		scheduleErr := fmt.Sprintf("Invalid publish_at: %v", err)
+		helperResponseError(w, http.StatusBadRequest, scheduleErr)
+		return
+	}
+
+	// lock the chirp so the publisher cannot release it mid-update
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		txErr := fmt.Sprintf("Error starting transaction: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, txErr)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	chirp, err := qtx.GetAChirpForUpdate(r.Context(), chirpID)
+	if err != nil {
+		getAChirpErr := fmt.Sprintf("Error retrieving chirp: %v", err)
+		helperResponseError(w, http.StatusNotFound, getAChirpErr)
+		return
+	}
+	if chirp.UserID != user {
+		userErr := "User not allowed to reschedule chirp"
+		helperResponseError(w, http.StatusForbidden, userErr)
+		return
+	}
+	if chirp.Published {
+		publishedErr := "Chirp already published"
+		helperResponseError(w, http.StatusConflict, publishedErr)
+		return
+	}
+
+	// a poll must still be open, and not open too long, at the new time. The
+	// poll row isn't locked: polls can't be edited once created, so the
+	// chirp lock is enough. An "edit poll" feature would need to lock it here.
+	poll, err := qtx.GetPoll(r.Context(), chirpID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		pollErr := fmt.Sprintf("Error retrieving poll: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, pollErr)
+		return
+	}
+	if err == nil {
+		err = helperValidatePollClose(poll.ClosesAt, params.PublishAt)
+		if err != nil {
+			scheduleErr := fmt.Sprintf("Invalid publish_at: %v", err)
+			helperResponseError(w, http.StatusBadRequest, scheduleErr)
+			return
+		}
+	}
+
+	dbChirp, err := qtx.RescheduleChirp(r.Context(), database.RescheduleChirpParams{
+		ID:        chirpID,
+		PublishAt: sql.NullTime{Time: params.PublishAt.UTC(), Valid: true},
+	})
+	if err != nil {
+		rescheduleErr := fmt.Sprintf("Error rescheduling chirp: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, rescheduleErr)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		commitErr := fmt.Sprintf("Error committing chirp schedule: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, commitErr)
+		return
+	}
+	updated, err := cfg.helperHydrateChirp(r.Context(), uuid.NullUUID{UUID: user, Valid: true}, dbChirp)
+	if err != nil {
+		hydrateErr := fmt.Sprintf("Error loading chirp details: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, hydrateErr)
+		return
+	}
+	helperResponseJSON(w, http.StatusOK, updated)
+}
+
+func (cfg *apiConfig) handlerCancelScheduledChirp(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		idErr := fmt.Sprintf("Invalid ID: %v", err)
+		helperResponseError(w, http.StatusBadRequest, idErr)
+		return
+	}
+
+	// validate token
+	user, err := cfg.helperAuthenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		txErr := fmt.Sprintf("Error starting transaction: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, txErr)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	chirp, err := qtx.GetAChirpForUpdate(r.Context(), chirpID)
+	if err != nil {
+		getAChirpErr := fmt.Sprintf("Error retrieving chirp: %v", err)
+		helperResponseError(w, http.StatusNotFound, getAChirpErr)
+		return
+	}
+	if chirp.UserID != user {
+		userErr := "User not allowed to cancel chirp"
+		helperResponseError(w, http.StatusForbidden, userErr)
+		return
+	}
+	if chirp.Published {
+		publishedErr := "Chirp already published"
+		helperResponseError(w, http.StatusConflict, publishedErr)
+		return
+	}
+
+	// nothing can reply to or rechirp a pending chirp, so it is removed outright
+	attachments, err := qtx.GetChirpAttachments(r.Context(), chirpID)
+	if err != nil {
+		attachmentsErr := fmt.Sprintf("Error retrieving attachments: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, attachmentsErr)
+		return
+	}
+	err = qtx.DeleteAChirp(r.Context(), chirpID)
+	if err != nil {
+		deleteAChirpErr := fmt.Sprintf("Error deleting chirp: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, deleteAChirpErr)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		commitErr := fmt.Sprintf("Error committing chirp cancellation: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, commitErr)
+		return
+	}
+	cfg.helperDeleteBlobs(r.Context(), attachments)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// runScheduledPublisher releases chirps whose publish time has passed every
+// scheduledPublishInterval until ctx is cancelled.
+func (cfg *apiConfig) runScheduledPublisher(ctx context.Context) {
+	ticker := time.NewTicker(scheduledPublishInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := cfg.db.PublishDueChirps(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to publish scheduled chirps: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seiobata/chirpy/internal/auth"
+	"github.com/seiobata/chirpy/internal/database"
+)
+
+// Session is one login, identified by its refresh token family.
+type Session struct {
+	ID         uuid.UUID `json:"id"`
+	DeviceName string    `json:"device_name"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	SignedInAt time.Time `json:"signed_in_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+func helperSessionFromDB(dbToken database.RefreshToken) Session {
+	return Session{
+		ID:         dbToken.FamilyID,
+		DeviceName: dbToken.DeviceName,
+		UserAgent:  dbToken.UserAgent,
+		IPAddress:  dbToken.IpAddress,
+		SignedInAt: dbToken.SignedInAt,
+		LastUsedAt: dbToken.LastUsedAt,
+		ExpiresAt:  dbToken.ExpiresAt,
+	}
+}
+
+// helperRevokeAllTokens ends every session of user, deletes their API keys
+// and bumps their token version, which retires all their access tokens. It
+// returns how many sessions ended and the new version, for callers to cache
+// once their transaction commits.
+func helperRevokeAllTokens(ctx context.Context, q *database.Queries, user uuid.UUID) (int64, int32, error) {
+	revoked, err := q.RevokeAllSessions(ctx, user)
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err := q.DeleteAllAPIKeys(ctx, user); err != nil {
+		return 0, 0, err
+	}
+	version, err := q.BumpTokenVersion(ctx, user)
+	if err != nil {
+		return 0, 0, err
+	}
+	return revoked, version, nil
+}
+
+func (cfg *apiConfig) handlerGetSessions(w http.ResponseWriter, r *http.Request) {
+	// validate token
+	user, err := cfg.helperAuthenticate(r, auth.ScopeAccount)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	dbTokens, err := cfg.db.GetSessions(r.Context(), user)
+	if err != nil {
+		getSessionsErr := fmt.Sprintf("Error retrieving sessions: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, getSessionsErr)
+		return
+	}
+	sessions := []Session{}
+	for _, dbToken := range dbTokens {
+		sessions = append(sessions, helperSessionFromDB(dbToken))
+	}
+	helperResponseJSON(w, http.StatusOK, sessions)
+}
+
+func (cfg *apiConfig) handlerRevokeSession(w http.ResponseWriter, r *http.Request) {
+	sessionID, err := uuid.Parse(r.PathValue("sessionID"))
+	if err != nil {
+		idErr := fmt.Sprintf("Invalid ID: %v", err)
+		helperResponseError(w, http.StatusBadRequest, idErr)
+		return
+	}
+
+	// validate token
+	user, err := cfg.helperAuthenticate(r, auth.ScopeAccount)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	// other users' sessions look the same as ones that don't exist
+	revoked, err := cfg.db.RevokeSession(r.Context(), database.RevokeSessionParams{
+		FamilyID: sessionID,
+		UserID:   user,
+	})
+	if err != nil {
+		revokeErr := fmt.Sprintf("Error revoking session: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, revokeErr)
+		return
+	}
+	if revoked == 0 {
+		notFoundErr := "Session not found"
+		helperResponseError(w, http.StatusNotFound, notFoundErr)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerRevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	// validate token
+	user, err := cfg.helperAuthenticate(r, auth.ScopeAccount)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		txErr := fmt.Sprintf("Error starting transaction: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, txErr)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	revoked, version, err := helperRevokeAllTokens(r.Context(), qtx, user)
+	if err != nil {
+		revokeErr := fmt.Sprintf("Error revoking sessions: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, revokeErr)
+		return
+	}
+	err = qtx.RecordSecurityEvent(r.Context(), database.RecordSecurityEventParams{
+		UserID: user,
+		Event:  securityEventSessionsRevoked,
+		Detail: fmt.Sprintf("revoked %d sessions, all access tokens and all API keys", revoked),
+	})
+	if err != nil {
+		eventErr := fmt.Sprintf("Error recording security event: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, eventErr)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		commitErr := fmt.Sprintf("Error committing session revocation: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, commitErr)
+		return
+	}
+	cfg.helperCacheTokenVersion(user, version)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/seiobata/chirpy/internal/database"
+)
+
+type ThreadNode struct {
+	Chirp
+	Replies []ThreadNode `json:"replies"`
+}
+
+type Thread struct {
+	Ancestors []Chirp    `json:"ancestors"`
+	Chirp     ThreadNode `json:"chirp"`
+}
+
+func (cfg *apiConfig) handlerGetChirpThread(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		idErr := fmt.Sprintf("Invalid ID: %v", err)
+		helperResponseError(w, http.StatusBadRequest, idErr)
+		return
+	}
+
+	// depth bounds both the ancestor chain and the reply tree
+	depth := defaultThreadDepth
+	depthStr := r.URL.Query().Get("depth")
+	if depthStr != "" {
+		depth, err = strconv.Atoi(depthStr)
+		if err != nil || depth < 1 {
+			depthErr := "Invalid depth: must be a positive integer"
+			helperResponseError(w, http.StatusBadRequest, depthErr)
+			return
+		}
+		depth = min(depth, maxThreadDepth)
+	}
+
+	// personalize the response when a token is supplied
+	viewer, err := cfg.helperOptionalUser(r)
+	if err != nil {
+		helperAuthError(w, err)
+		return
+	}
+
+	// a deleted root still anchors its live replies, shown as a placeholder
+	dbChirp, err := cfg.db.GetThreadRoot(r.Context(), chirpID)
+	if err != nil {
+		getAChirpErr := fmt.Sprintf("Error retrieving chirp: %v", err)
+		helperResponseError(w, http.StatusNotFound, getAChirpErr)
+		return
+	}
+
+	dbAncestors, err := cfg.db.GetChirpAncestors(r.Context(), database.GetChirpAncestorsParams{
+		ChirpID:  chirpID,
+		MaxDepth: int32(depth),
+	})
+	if err != nil {
+		ancestorsErr := fmt.Sprintf("Error retrieving thread ancestors: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, ancestorsErr)
+		return
+	}
+	dbDescendants, err := cfg.db.GetChirpDescendants(r.Context(), database.GetChirpDescendantsParams{
+		ChirpID:  chirpID,
+		MaxDepth: int32(depth),
+		Limit:    maxThreadReplies,
+	})
+	if err != nil {
+		descendantsErr := fmt.Sprintf("Error retrieving thread replies: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, descendantsErr)
+		return
+	}
+
+	// hydrate the whole thread in one batch, then split it back apart
+	dbThread := append([]database.Chirp{dbChirp}, dbAncestors...)
+	dbThread = append(dbThread, dbDescendants...)
+	chirps, err := cfg.helperHydrateChirps(r.Context(), viewer, dbThread)
+	if err != nil {
+		hydrateErr := fmt.Sprintf("Error loading chirp details: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, hydrateErr)
+		return
+	}
+	root := chirps[0]
+	ancestors := chirps[1 : 1+len(dbAncestors)]
+	replies := chirps[1+len(dbAncestors):]
+
+	// group replies under their parents; rows arrive oldest first
+	children := map[uuid.UUID][]Chirp{}
+	for _, reply := range replies {
+		children[reply.ParentChirpID.UUID] = append(children[reply.ParentChirpID.UUID], reply)
+	}
+
+	node := helperBuildThreadNode(root, children)
+	if node.Deleted && len(node.Replies) == 0 {
+		notFoundErr := "Chirp not found"
+		helperResponseError(w, http.StatusNotFound, notFoundErr)
+		return
+	}
+
+	helperResponseJSON(w, http.StatusOK, Thread{
+		Ancestors: ancestors,
+		Chirp:     node,
+	})
+}
+
+func helperBuildThreadNode(chirp Chirp, children map[uuid.UUID][]Chirp) ThreadNode {
+	node := ThreadNode{
+		Chirp:   chirp,
+		Replies: []ThreadNode{},
+	}
+	for _, child := range children[chirp.ID] {
+		// deleted replies only stay to hold up the replies beneath them
+		childNode := helperBuildThreadNode(child, children)
+		if childNode.Deleted && len(childNode.Replies) == 0 {
+			continue
+		}
+		node.Replies = append(node.Replies, childNode)
+	}
+	return node
+}
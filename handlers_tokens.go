@@ -1,40 +1,141 @@
 package main
 
 import (
+	"database/sql"
 	"fmt"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/seiobata/chirpy/internal/auth"
+	"github.com/seiobata/chirpy/internal/database"
+)
+
+// security events
+const (
+	securityEventRefreshTokenReuse = "refresh_token_reuse"
+	securityEventSessionsRevoked   = "sessions_revoked"
+	securityEventPasswordChanged   = "password_changed"
 )
 
 func (cfg *apiConfig) handlerRefreshAccessToken(w http.ResponseWriter, r *http.Request) {
 	type response struct {
-		AccessToken string `json:"token"`
+		AccessToken  string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}
 
-	// validate refresh token
 	invalidErr := "Token is invalid or expired"
 	refreshToken, err := auth.GetBearerToken(r.Header)
 	if err != nil {
 		helperResponseError(w, http.StatusUnauthorized, invalidErr)
 		return
 	}
-	user, err := cfg.db.GetUserFromRefreshToken(r.Context(), refreshToken)
+
+	// lock the token so concurrent refreshes rotate it only once
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		txErr := fmt.Sprintf("Error starting transaction: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, txErr)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	dbToken, err := qtx.GetRefreshTokenForUpdate(r.Context(), refreshToken)
 	if err != nil {
 		helperResponseError(w, http.StatusUnauthorized, invalidErr)
 		return
 	}
 
-	// generate new access token
-	accessToken, err := auth.MakeJWT(user.ID, cfg.secret, accessTkExp)
+	// a token that was already rotated should never come back; if it does,
+	// someone else has a copy, so end the whole login
+	if dbToken.ReplacedBy.Valid {
+		revoked, err := qtx.RevokeRefreshTokenFamily(r.Context(), dbToken.FamilyID)
+		if err != nil {
+			revokeErr := fmt.Sprintf("Error revoking refresh tokens: %v", err)
+			helperResponseError(w, http.StatusInternalServerError, revokeErr)
+			return
+		}
+		detail := fmt.Sprintf("rotated refresh token reused; revoked %d tokens in family %s", revoked, dbToken.FamilyID)
+		err = qtx.RecordSecurityEvent(r.Context(), database.RecordSecurityEventParams{
+			UserID: dbToken.UserID,
+			Event:  securityEventRefreshTokenReuse,
+			Detail: detail,
+		})
+		if err != nil {
+			eventErr := fmt.Sprintf("Error recording security event: %v", err)
+			helperResponseError(w, http.StatusInternalServerError, eventErr)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			commitErr := fmt.Sprintf("Error committing token revocation: %v", err)
+			helperResponseError(w, http.StatusInternalServerError, commitErr)
+			return
+		}
+		log.Printf("Security event for user %s: %s", dbToken.UserID, detail)
+		helperResponseError(w, http.StatusUnauthorized, invalidErr)
+		return
+	}
+	if dbToken.RevokedAt.Valid || !time.Now().UTC().Before(dbToken.ExpiresAt) {
+		helperResponseError(w, http.StatusUnauthorized, invalidErr)
+		return
+	}
+
+	// generate new access token at the user's current token version
+	version, err := qtx.GetTokenVersion(r.Context(), dbToken.UserID)
+	if err != nil {
+		versionErr := fmt.Sprintf("Error retrieving token version: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, versionErr)
+		return
+	}
+	accessToken, err := cfg.tokenKeys.MakeJWT(dbToken.UserID, version, loginScopes, accessTkExp)
 	if err != nil {
 		makeJWTErr := fmt.Sprintf("Error making JWT token: %v", err)
 		helperResponseError(w, http.StatusInternalServerError, makeJWTErr)
 		return
 	}
 
+	// replace the refresh token with a new one in the same family, keeping
+	// the session's device and sign-in time
+	newRefreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		makeRefreshTkErr := fmt.Sprintf("Error generating refresh token: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, makeRefreshTkErr)
+		return
+	}
+	_, err = qtx.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
+		Token:      newRefreshToken,
+		UserID:     dbToken.UserID,
+		ExpiresAt:  time.Now().UTC().Add(refreshTkExp),
+		FamilyID:   dbToken.FamilyID,
+		DeviceName: dbToken.DeviceName,
+		UserAgent:  r.UserAgent(),
+		IpAddress:  helperClientIP(r),
+		SignedInAt: dbToken.SignedInAt,
+	})
+	if err != nil {
+		dbRefreshTkErr := fmt.Sprintf("Error adding refresh token to database: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, dbRefreshTkErr)
+		return
+	}
+	err = qtx.ReplaceRefreshToken(r.Context(), database.ReplaceRefreshTokenParams{
+		Token:      refreshToken,
+		ReplacedBy: sql.NullString{String: newRefreshToken, Valid: true},
+	})
+	if err != nil {
+		replaceErr := fmt.Sprintf("Error revoking refresh token: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, replaceErr)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		commitErr := fmt.Sprintf("Error committing refresh token: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, commitErr)
+		return
+	}
+
 	helperResponseJSON(w, http.StatusOK, response{
-		AccessToken: accessToken,
+		AccessToken:  accessToken,
+		RefreshToken: newRefreshToken,
 	})
 }
 
@@ -55,3 +156,10 @@ func (cfg *apiConfig) handlerRevokeRefreshToken(w http.ResponseWriter, r *http.R
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// handlerJWKS publishes the public keys access tokens are signed with, so
+// other services can verify them without holding a secret.
+func (cfg *apiConfig) handlerJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	helperResponseJSON(w, http.StatusOK, cfg.tokenKeys.JWKS())
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -9,20 +12,82 @@ import (
 	"github.com/google/uuid"
 	"github.com/seiobata/chirpy/internal/auth"
 	"github.com/seiobata/chirpy/internal/database"
+	"github.com/seiobata/chirpy/internal/entities"
 )
 
 type User struct {
-	ID          uuid.UUID `json:"id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Email       string    `json:"email"`
-	IsChirpyRed bool      `json:"is_chirpy_red"`
+	ID           uuid.UUID  `json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	Email        string     `json:"email"`
+	IsChirpyRed  bool       `json:"is_chirpy_red"`
+	RedExpiresAt *time.Time `json:"red_expires_at,omitempty"`
+	Handle       string     `json:"handle"`
+}
+
+func helperUserFromDB(dbUser database.User) User {
+	user := User{
+		ID:          dbUser.ID,
+		CreatedAt:   dbUser.CreatedAt,
+		UpdatedAt:   dbUser.UpdatedAt,
+		Email:       dbUser.Email,
+		IsChirpyRed: dbUser.IsChirpyRed,
+		Handle:      dbUser.Handle.String,
+	}
+	if dbUser.RedExpiresAt.Valid {
+		user.RedExpiresAt = &dbUser.RedExpiresAt.Time
+	}
+	return user
+}
+
+// usersHandleConstraint is broken when two users claim a free handle at
+// the same time; helperClaimHandle only sees it free.
+const usersHandleConstraint = "users_handle_key"
+
+var (
+	errInvalidHandle = errors.New("handle must be 3 to 30 letters, digits or underscores")
+	errHandleTaken   = errors.New("handle is already taken")
+)
+
+// helperClaimHandle normalizes a requested handle and checks that no other
+// user holds it. An empty request yields an invalid NullString.
+func (cfg *apiConfig) helperClaimHandle(ctx context.Context, requested string, userID uuid.UUID) (sql.NullString, error) {
+	if requested == "" {
+		return sql.NullString{}, nil
+	}
+	handle, ok := entities.NormalizeHandle(requested)
+	if !ok {
+		return sql.NullString{}, errInvalidHandle
+	}
+	claimed := sql.NullString{String: handle, Valid: true}
+	owner, err := cfg.db.GetUserByHandle(ctx, claimed)
+	if err == nil && owner.ID != userID {
+		return sql.NullString{}, errHandleTaken
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return sql.NullString{}, err
+	}
+	return claimed, nil
+}
+
+func helperHandleError(w http.ResponseWriter, err error) {
+	handleErr := fmt.Sprintf("Invalid handle: %v", err)
+	switch {
+	case errors.Is(err, errInvalidHandle):
+		helperResponseError(w, http.StatusBadRequest, handleErr)
+	case errors.Is(err, errHandleTaken):
+		helperResponseError(w, http.StatusConflict, handleErr)
+	default:
+		checkErr := fmt.Sprintf("Error checking handle: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, checkErr)
+	}
 }
 
 func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		Handle   string `json:"handle"`
 	}
 	params := parameters{}
 
@@ -33,6 +98,11 @@ func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request)
 		helperResponseError(w, http.StatusBadRequest, decodeErr)
 		return
 	}
+	handle, err := cfg.helperClaimHandle(r.Context(), params.Handle, uuid.Nil)
+	if err != nil {
+		helperHandleError(w, err)
+		return
+	}
 	hashedPass, err := auth.HashPassword(params.Password)
 	if err != nil {
 		hashErr := fmt.Sprintf("Error hashing password: %v", err)
@@ -42,37 +112,35 @@ func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request)
 	user, err := cfg.db.CreateUser(r.Context(), database.CreateUserParams{
 		Email:          params.Email,
 		HashedPassword: hashedPass,
+		Handle:         handle,
 	})
+	if helperIsUniqueViolation(err, usersHandleConstraint) {
+		helperHandleError(w, errHandleTaken)
+		return
+	}
 	if err != nil {
 		createUserErr := fmt.Sprintf("Error creating user: %v", err)
 		helperResponseError(w, http.StatusInternalServerError, createUserErr)
 		return
 	}
-	helperResponseJSON(w, http.StatusCreated, User{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed,
-	})
+	helperResponseJSON(w, http.StatusCreated, helperUserFromDB(user))
 }
 
 func (cfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email    *string `json:"email"`
+		Password *string `json:"password"`
+		Handle   string  `json:"handle"`
 	}
 
 	// validate token
-	invalidErr := "Token is invalid or expired"
-	token, err := auth.GetBearerToken(r.Header)
+	user, scopes, err := cfg.helperCredential(r)
 	if err != nil {
-		helperResponseError(w, http.StatusUnauthorized, invalidErr)
+		helperAuthError(w, err)
 		return
 	}
-	user, err := auth.ValidateJWT(token, cfg.secret)
-	if err != nil {
-		helperResponseError(w, http.StatusUnauthorized, invalidErr)
+	if err := helperRequireScope(scopes, auth.ScopeProfileWrite); err != nil {
+		helperAuthError(w, err)
 		return
 	}
 
@@ -86,40 +154,118 @@ func (cfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// hash password
-	password, err := auth.HashPassword(params.Password)
+	// profile:write covers the handle; sign-in details need the account scope
+	if params.Email != nil || params.Password != nil {
+		if err := helperRequireScope(scopes, auth.ScopeAccount); err != nil {
+			helperAuthError(w, err)
+			return
+		}
+	}
+	if params.Email != nil && *params.Email == "" {
+		emailErr := "Email must not be empty"
+		helperResponseError(w, http.StatusBadRequest, emailErr)
+		return
+	}
+	if params.Password != nil && *params.Password == "" {
+		passwordErr := "Password must not be empty"
+		helperResponseError(w, http.StatusBadRequest, passwordErr)
+		return
+	}
+
+	// an omitted handle leaves the current one in place
+	handle, err := cfg.helperClaimHandle(r.Context(), params.Handle, user)
 	if err != nil {
-		hashErr := fmt.Sprintf("Error hashing password: %v", err)
-		helperResponseError(w, http.StatusInternalServerError, hashErr)
+		helperHandleError(w, err)
+		return
+	}
+
+	// omitted sign-in details are left as they are
+	current, err := cfg.db.GetUserByID(r.Context(), user)
+	if err != nil {
+		getUserErr := fmt.Sprintf("Error retrieving user: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, getUserErr)
+		return
+	}
+	email := current.Email
+	if params.Email != nil {
+		email = *params.Email
+	}
+	password := current.HashedPassword
+	passwordChanged := false
+	if params.Password != nil && auth.CheckPasswordHash(*params.Password, current.HashedPassword) != nil {
+		passwordChanged = true
+		password, err = auth.HashPassword(*params.Password)
+		if err != nil {
+			hashErr := fmt.Sprintf("Error hashing password: %v", err)
+			helperResponseError(w, http.StatusInternalServerError, hashErr)
+			return
+		}
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		txErr := fmt.Sprintf("Error starting transaction: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, txErr)
 		return
 	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
 
 	// update user email and password
-	dbUser, err := cfg.db.UpdateUser(r.Context(), database.UpdateUserParams{
+	dbUser, err := qtx.UpdateUser(r.Context(), database.UpdateUserParams{
 		ID:             user,
-		Email:          params.Email,
+		Email:          email,
 		HashedPassword: password,
+		Handle:         handle,
 	})
+	if helperIsUniqueViolation(err, usersHandleConstraint) {
+		helperHandleError(w, errHandleTaken)
+		return
+	}
 	if err != nil {
 		updateErr := fmt.Sprintf("Error updating user: %v", err)
 		helperResponseError(w, http.StatusInternalServerError, updateErr)
 		return
 	}
 
+	// a new password signs the user out everywhere, this session included
+	if passwordChanged {
+		revoked, version, err := helperRevokeAllTokens(r.Context(), qtx, user)
+		if err != nil {
+			revokeErr := fmt.Sprintf("Error revoking sessions: %v", err)
+			helperResponseError(w, http.StatusInternalServerError, revokeErr)
+			return
+		}
+		dbUser.TokenVersion = version
+		err = qtx.RecordSecurityEvent(r.Context(), database.RecordSecurityEventParams{
+			UserID: user,
+			Event:  securityEventPasswordChanged,
+			Detail: fmt.Sprintf("password changed; revoked %d sessions, all access tokens and all API keys", revoked),
+		})
+		if err != nil {
+			eventErr := fmt.Sprintf("Error recording security event: %v", err)
+			helperResponseError(w, http.StatusInternalServerError, eventErr)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		commitErr := fmt.Sprintf("Error committing user update: %v", err)
+		helperResponseError(w, http.StatusInternalServerError, commitErr)
+		return
+	}
+	if passwordChanged {
+		cfg.helperCacheTokenVersion(user, dbUser.TokenVersion)
+	}
+
 	// request successful; returning user
-	helperResponseJSON(w, http.StatusOK, User{
-		ID:          dbUser.ID,
-		CreatedAt:   dbUser.CreatedAt,
-		UpdatedAt:   dbUser.UpdatedAt,
-		Email:       dbUser.Email,
-		IsChirpyRed: dbUser.IsChirpyRed,
-	})
+	helperResponseJSON(w, http.StatusOK, helperUserFromDB(dbUser))
 }
 
 func (cfg *apiConfig) handlerUserLogin(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email      string `json:"email"`
+		Password   string `json:"password"`
+		DeviceName string `json:"device_name"`
 	}
 	type response struct {
 		User
@@ -136,6 +282,12 @@ func (cfg *apiConfig) handlerUserLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if len([]rune(params.DeviceName)) > maxDeviceNameLength {
+		deviceErr := fmt.Sprintf("Device name must be at most %d characters", maxDeviceNameLength)
+		helperResponseError(w, http.StatusBadRequest, deviceErr)
+		return
+	}
+
 	// check email and password
 	invalidErr := "Incorrect email or password"
 	user, err := cfg.db.GetUser(r.Context(), params.Email)
@@ -150,7 +302,7 @@ func (cfg *apiConfig) handlerUserLogin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// generate access token
-	accessToken, err := auth.MakeJWT(user.ID, cfg.secret, accessTkExp)
+	accessToken, err := cfg.tokenKeys.MakeJWT(user.ID, user.TokenVersion, loginScopes, accessTkExp)
 	if err != nil {
 		makeJWTErr := fmt.Sprintf("Error generating JWT token: %v", err)
 		helperResponseError(w, http.StatusInternalServerError, makeJWTErr)
@@ -165,11 +317,18 @@ func (cfg *apiConfig) handlerUserLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// add refresh token to refresh_tokens database
+	// add refresh token to refresh_tokens database; each login starts a new
+	// token family, which is listed as a session
+	now := time.Now().UTC()
 	_, err = cfg.db.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
-		UserID:    user.ID,
-		Token:     refreshToken,
-		ExpiresAt: time.Now().UTC().Add(refreshTkExp),
+		UserID:     user.ID,
+		Token:      refreshToken,
+		ExpiresAt:  now.Add(refreshTkExp),
+		FamilyID:   uuid.New(),
+		DeviceName: params.DeviceName,
+		UserAgent:  r.UserAgent(),
+		IpAddress:  helperClientIP(r),
+		SignedInAt: now,
 	})
 	if err != nil {
 		dbRefreshTkErr := fmt.Sprintf("Error adding refresh token to database: %v", err)
@@ -178,69 +337,8 @@ func (cfg *apiConfig) handlerUserLogin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	helperResponseJSON(w, http.StatusOK, response{
-		User: User{
-			ID:          user.ID,
-			CreatedAt:   user.CreatedAt,
-			UpdatedAt:   user.UpdatedAt,
-			Email:       user.Email,
-			IsChirpyRed: user.IsChirpyRed,
-		},
+		User:         helperUserFromDB(user),
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
 	})
 }
-
-func (cfg *apiConfig) handlerUpgradeUserToRed(w http.ResponseWriter, r *http.Request) {
-	type parameters struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID string `json:"user_id"`
-		} `json:"data"`
-	}
-
-	// check for Polka API Key
-	apiKeyErr := "Invalid API Key"
-	apiKey, err := auth.GetAPIKey(r.Header)
-	if err != nil {
-		helperResponseError(w, http.StatusUnauthorized, apiKeyErr)
-		return
-	}
-	if apiKey != cfg.polkaSecret {
-		helperResponseError(w, http.StatusUnauthorized, apiKeyErr)
-		return
-	}
-
-	params := parameters{}
-	decoder := json.NewDecoder(r.Body)
-	err = decoder.Decode(&params)
-	if err != nil {
-		decodeErr := fmt.Sprintf("Error decoding JSON: %v", err)
-		helperResponseError(w, http.StatusBadRequest, decodeErr)
-		return
-	}
-
-	// get user ID
-	user, err := uuid.Parse(params.Data.UserID)
-	if err != nil {
-		idErr := fmt.Sprintf("Error parsing ID: %v", err)
-		helperResponseError(w, http.StatusBadRequest, idErr)
-		return
-	}
-
-	// filter for user.upgraded event
-	if params.Event != "user.upgraded" {
-		w.WriteHeader(http.StatusNoContent)
-		return
-	}
-
-	// upgrade user in database
-	err = cfg.db.UserIsChirpyRed(r.Context(), user)
-	if err != nil {
-		isChirpyRedErr := fmt.Sprintf("Error updating user: %v", err)
-		helperResponseError(w, http.StatusNotFound, isChirpyRedErr)
-		return
-	}
-
-	// successful request; return 'no content' header
-	w.WriteHeader(http.StatusNoContent)
-}
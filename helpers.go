@@ -1,32 +1,66 @@
 package main
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/seiobata/chirpy/internal/auth"
+	"github.com/seiobata/chirpy/internal/chirptext"
+	"github.com/seiobata/chirpy/internal/database"
+	"github.com/seiobata/chirpy/internal/plans"
 )
 
-func helperValidateBody(body string) (string, error) {
-	if len(body) > maxChirpLength {
-		return "", errors.New("chirp is too long")
+// helperValidateBody normalizes a chirp body, runs it through the content
+// filter and checks the filtered text's length in visible characters against
+// the author's limit, since replacements can make it longer. It returns the
+// cleaned body and the IDs of any flag rules it matched.
+func (cfg *apiConfig) helperValidateBody(body string, maxLength int) (string, []uuid.UUID, error) {
+	result := cfg.contentFilter.Filter(chirptext.Normalize(body))
+	if result.Rejected {
+		return "", nil, errors.New("chirp contains content that is not allowed")
 	}
 
-	badWords := map[string]struct{}{
-		"kerfuffle": {},
-		"sharbert":  {},
-		"fornax":    {},
+	body, err := chirptext.Check(result.Text, maxLength)
+	if err != nil {
+		return "", nil, err
 	}
-	words := strings.Split(body, " ")
-	for i, word := range words {
-		lowerWord := strings.ToLower(word)
-		if _, ok := badWords[lowerWord]; ok {
-			words[i] = "****"
-		}
+	return body, result.Flagged, nil
+}
+
+// helperEntitlements looks up what a user's plan unlocks.
+func (cfg *apiConfig) helperEntitlements(ctx context.Context, userID uuid.UUID) (plans.Entitlements, error) {
+	user, err := cfg.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return plans.Entitlements{}, err
 	}
-	cleanWords := strings.Join(words, " ")
-	return cleanWords, nil
+	return plans.ForUser(user.IsChirpyRed).Entitlements(), nil
+}
+
+// helperAllowChirp counts a new chirp against the user's rate limit. When the
+// limit is reached it writes a 429 response and reports false. The limit is
+// kept per instance, so across n instances a user may post n times the rate.
+func (cfg *apiConfig) helperAllowChirp(w http.ResponseWriter, userID uuid.UUID, ent plans.Entitlements) bool {
+	ok, wait := cfg.chirpLimiter.Allow(userID.String(), ent.ChirpRate)
+	if ok {
+		return true
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	rateErr := "Too many chirps; try again later"
+	helperResponseError(w, http.StatusTooManyRequests, rateErr)
+	return false
 }
 
 func helperResponseError(w http.ResponseWriter, code int, msg string) {
@@ -51,3 +85,205 @@ func helperResponseJSON(w http.ResponseWriter, code int, payload any) {
 	w.WriteHeader(code)
 	w.Write(data)
 }
+
+// helperEncodeCursor packs the position of the last item on a page into an
+// opaque string that clients pass back to fetch the next page.
+func helperEncodeCursor(createdAt time.Time, id uuid.UUID) string {
+	raw := fmt.Sprintf("%s|%s", createdAt.UTC().Format(time.RFC3339Nano), id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func helperDecodeCursor(cursor string) (time.Time, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, uuid.Nil, errors.New("malformed cursor")
+	}
+	createdStr, idStr, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return time.Time{}, uuid.Nil, errors.New("malformed cursor")
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, createdStr)
+	if err != nil {
+		return time.Time{}, uuid.Nil, errors.New("malformed cursor")
+	}
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return time.Time{}, uuid.Nil, errors.New("malformed cursor")
+	}
+	return createdAt, id, nil
+}
+
+// helperPageLimit reads the limit query parameter, falling back to
+// defaultPageLimit and capping it at maxPageLimit.
+func helperPageLimit(r *http.Request) (int, error) {
+	limitStr := r.URL.Query().Get("limit")
+	if limitStr == "" {
+		return defaultPageLimit, nil
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 {
+		return 0, errors.New("limit must be a positive integer")
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+	return limit, nil
+}
+
+// helperIsUniqueViolation reports whether err came from breaking the named
+// unique constraint or index, such as when two requests race to insert the
+// same row.
+func helperIsUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
+
+// helperClientIP returns the address the request came from, without its port.
+func helperClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// missingScopeError reports a valid credential that lacks the scope an
+// endpoint requires.
+type missingScopeError struct {
+	scope string
+}
+
+func (e missingScopeError) Error() string {
+	return fmt.Sprintf("token lacks the %s scope", e.scope)
+}
+
+// helperAuthenticate identifies the caller from a bearer access token or API
+// key, and checks that the credential carries scope.
+func (cfg *apiConfig) helperAuthenticate(r *http.Request, scope string) (uuid.UUID, error) {
+	userID, scopes, err := cfg.helperCredential(r)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if err := helperRequireScope(scopes, scope); err != nil {
+		return uuid.Nil, err
+	}
+	return userID, nil
+}
+
+// helperCredential identifies the caller from a bearer access token or API
+// key and returns the scopes the credential carries, for endpoints whose
+// required scope depends on the request.
+func (cfg *apiConfig) helperCredential(r *http.Request) (uuid.UUID, []string, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+
+	if auth.IsAPIKey(token) {
+		// expired and deleted keys aren't found
+		key, err := cfg.db.GetAPIKeyByHash(r.Context(), auth.HashAPIKey(token))
+		if err != nil {
+			return uuid.Nil, nil, err
+		}
+		// last use is only recorded every apiKeyTouchInterval, sparing a
+		// write per request
+		staleBefore := time.Now().UTC().Add(-apiKeyTouchInterval)
+		if !key.LastUsedAt.Valid || key.LastUsedAt.Time.Before(staleBefore) {
+			err = cfg.db.TouchAPIKey(r.Context(), database.TouchAPIKeyParams{
+				ID:          key.ID,
+				StaleBefore: staleBefore,
+			})
+			if err != nil {
+				return uuid.Nil, nil, err
+			}
+		}
+		return key.UserID, key.Scopes, nil
+	}
+
+	claims, err := cfg.helperValidateAccessToken(r.Context(), token)
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+	// tokens issued before scopes existed all came from a login
+	if claims.Scopes == nil {
+		return claims.UserID, loginScopes, nil
+	}
+	return claims.UserID, claims.Scopes, nil
+}
+
+// helperRequireScope checks that a credential's scopes include scope.
+func helperRequireScope(scopes []string, scope string) error {
+	if !slices.Contains(scopes, scope) {
+		return missingScopeError{scope: scope}
+	}
+	return nil
+}
+
+// helperAuthError responds to a failed helperAuthenticate: 403 when the
+// credential is good but lacks the scope, 401 otherwise.
+func helperAuthError(w http.ResponseWriter, err error) {
+	var scopeErr missingScopeError
+	if errors.As(err, &scopeErr) {
+		scopeMsg := fmt.Sprintf("Token lacks the %s scope", scopeErr.scope)
+		helperResponseError(w, http.StatusForbidden, scopeMsg)
+		return
+	}
+	invalidErr := "Token is invalid or expired"
+	helperResponseError(w, http.StatusUnauthorized, invalidErr)
+}
+
+// helperValidateAccessToken validates a bearer JWT and checks that it was
+// issued at the user's current token version.
+func (cfg *apiConfig) helperValidateAccessToken(ctx context.Context, token string) (auth.AccessClaims, error) {
+	claims, err := cfg.tokenKeys.ParseJWT(token)
+	if err != nil {
+		return auth.AccessClaims{}, err
+	}
+	version, err := cfg.helperTokenVersion(ctx, claims.UserID)
+	if err != nil {
+		return auth.AccessClaims{}, err
+	}
+	if claims.TokenVersion != version {
+		return auth.AccessClaims{}, errors.New("token has been revoked")
+	}
+	return claims, nil
+}
+
+// helperTokenVersion returns the user's current token version. Versions are
+// cached for tokenVersionCacheTTL to spare a database read per request;
+// bumps made here update the cache at once, but other instances only see
+// them once their entry expires, so they keep accepting revoked access
+// tokens for up to tokenVersionCacheTTL.
+func (cfg *apiConfig) helperTokenVersion(ctx context.Context, userID uuid.UUID) (int32, error) {
+	if version, ok := cfg.tokenVersions.Get(userID); ok {
+		return version, nil
+	}
+	version, err := cfg.db.GetTokenVersion(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	cfg.helperCacheTokenVersion(userID, version)
+	return version, nil
+}
+
+// helperCacheTokenVersion caches version for the user unless a newer one is
+// already cached; a read that started before a revocation must not undo it.
+func (cfg *apiConfig) helperCacheTokenVersion(userID uuid.UUID, version int32) {
+	cfg.tokenVersions.SetIf(userID, version, func(current int32) bool {
+		return version > current
+	})
+}
+
+// helperOptionalUser returns the caller's user ID when the request carries a
+// bearer token, letting public endpoints personalize their responses. The
+// token must be able to read chirps.
+func (cfg *apiConfig) helperOptionalUser(r *http.Request) (uuid.NullUUID, error) {
+	if r.Header.Get("Authorization") == "" {
+		return uuid.NullUUID{}, nil
+	}
+	userID, err := cfg.helperAuthenticate(r, auth.ScopeChirpsRead)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	return uuid.NullUUID{UUID: userID, Valid: true}, nil
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Scopes name what a bearer credential may do. Access tokens from a login
+// carry all of them; API keys carry the ones their owner picked.
+const (
+	ScopeChirpsRead   = "chirps:read"
+	ScopeChirpsWrite  = "chirps:write"
+	ScopeProfileWrite = "profile:write"
+	// ScopeAccount covers sign-in management: sessions, API keys, email and
+	// password. API keys are never granted it.
+	ScopeAccount = "account"
+)
+
+// APIKeyPrefix starts every API key, telling keys apart from JWTs when both
+// arrive as bearer tokens.
+const APIKeyPrefix = "chirpy_"
+
+// apiKeyDisplayLength is how much of a key is kept in the clear so owners can
+// recognize it in a listing.
+const apiKeyDisplayLength = len(APIKeyPrefix) + 6
+
+// MakeAPIKey generates a new API key.
+func MakeAPIKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return APIKeyPrefix + hex.EncodeToString(key), nil
+}
+
+// IsAPIKey reports whether a bearer token looks like an API key.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// HashAPIKey returns the hash an API key is stored and looked up by. Keys are
+// random enough that a fast hash is safe, unlike passwords.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyDisplayPrefix returns the start of an API key, safe to show back to
+// its owner.
+func APIKeyDisplayPrefix(key string) string {
+	if len(key) <= apiKeyDisplayLength {
+		return key
+	}
+	return key[:apiKeyDisplayLength]
+}
@@ -1,10 +1,18 @@
 package auth
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
 	"net/http"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
@@ -90,6 +98,59 @@ func TestMakeAndValidateJWT(t *testing.T) {
 	}
 }
 
+func TestParseJWT(t *testing.T) {
+	userID := uuid.New()
+	before := time.Now().Truncate(time.Second)
+
+	token, err := MakeJWT(userID, "secret", time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+	claims, err := ParseJWT(token, "secret")
+	if err != nil {
+		t.Fatalf("ParseJWT failed: %v", err)
+	}
+	if claims.UserID != userID {
+		t.Fatalf("Expected userID %v, got %v", userID, claims.UserID)
+	}
+	if claims.IssuedAt.Before(before) || claims.IssuedAt.After(time.Now()) {
+		t.Fatalf("Expected issued at to be now, got %v", claims.IssuedAt)
+	}
+	if claims.TokenVersion != 0 {
+		t.Fatalf("Expected token version 0, got %d", claims.TokenVersion)
+	}
+
+	other, err := MakeJWT(userID, "secret", time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+	otherClaims, err := ParseJWT(other, "secret")
+	if err != nil {
+		t.Fatalf("ParseJWT failed: %v", err)
+	}
+	if claims.ID == "" || claims.ID == otherClaims.ID {
+		t.Fatalf("Expected distinct jti claims, got %q and %q", claims.ID, otherClaims.ID)
+	}
+}
+
+func TestKeySetTokenVersion(t *testing.T) {
+	ks, err := NewKeySet(NewHMACKey("hs", []byte("secret")))
+	if err != nil {
+		t.Fatalf("NewKeySet failed: %v", err)
+	}
+	token, err := ks.MakeJWT(uuid.New(), 7, nil, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+	claims, err := ks.ParseJWT(token)
+	if err != nil {
+		t.Fatalf("ParseJWT failed: %v", err)
+	}
+	if claims.TokenVersion != 7 {
+		t.Fatalf("Expected token version 7, got %d", claims.TokenVersion)
+	}
+}
+
 func TestGetBearerToken(t *testing.T) {
 	userID := uuid.New()
 	token, err := MakeJWT(userID, "secret", time.Minute)
@@ -110,3 +171,257 @@ func TestGetBearerToken(t *testing.T) {
 		t.Fatal("getToken does not match token")
 	}
 }
+
+func TestVerifyWebhook(t *testing.T) {
+	body := []byte(`{"id":"evt_1","event":"user.upgraded"}`)
+	now := time.Unix(1700000000, 0)
+	timestamp := "1700000000"
+	tolerance := time.Minute * 5
+	secrets := []string{"new-secret", "old-secret"}
+
+	for _, secret := range secrets {
+		signature := SignWebhook(secret, timestamp, body)
+		if err := VerifyWebhook(secrets, timestamp, signature, body, now, tolerance); err != nil {
+			t.Fatalf("Expected signature from %q to verify, got %v", secret, err)
+		}
+	}
+
+	signature := SignWebhook("new-secret", timestamp, body)
+	cases := []struct {
+		name      string
+		secrets   []string
+		timestamp string
+		signature string
+		body      []byte
+		now       time.Time
+	}{
+		{"unknown secret", []string{"other"}, timestamp, signature, body, now},
+		{"tampered body", secrets, timestamp, signature, []byte(`{"id":"evt_1","event":"user.downgraded"}`), now},
+		{"tampered timestamp", secrets, "1700000001", signature, body, now.Add(time.Second)},
+		{"too old", secrets, timestamp, signature, body, now.Add(tolerance + time.Second)},
+		{"too new", secrets, timestamp, signature, body, now.Add(-tolerance - time.Second)},
+		{"malformed timestamp", secrets, "yesterday", signature, body, now},
+		{"malformed signature", secrets, timestamp, "not-hex", body, now},
+		{"empty secret", []string{""}, timestamp, SignWebhook("", timestamp, body), body, now},
+	}
+	for _, c := range cases {
+		err := VerifyWebhook(c.secrets, c.timestamp, c.signature, c.body, c.now, tolerance)
+		if !errors.Is(err, ErrInvalidSignature) {
+			t.Fatalf("%s: expected ErrInvalidSignature, got %v", c.name, err)
+		}
+	}
+}
+
+func makeKeyPEM(t *testing.T, id string, priv any) *Key {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey failed: %v", err)
+	}
+	key, err := ParseKeyPEM(id, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		t.Fatalf("ParseKeyPEM failed: %v", err)
+	}
+	return key
+}
+
+func TestKeySetRotation(t *testing.T) {
+	_, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	oldKey := makeKeyPEM(t, "2024-rsa", rsaPriv)
+	newKey := makeKeyPEM(t, "2025-ed", edPriv)
+	userID := uuid.New()
+
+	oldSet, err := NewKeySet(oldKey)
+	if err != nil {
+		t.Fatalf("NewKeySet failed: %v", err)
+	}
+	oldToken, err := oldSet.MakeJWT(userID, 0, nil, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+
+	// the public half of the old key is enough to keep verifying
+	pubDER, err := x509.MarshalPKIXPublicKey(&rsaPriv.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey failed: %v", err)
+	}
+	oldPublic, err := ParseKeyPEM("2024-rsa", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}))
+	if err != nil {
+		t.Fatalf("ParseKeyPEM failed: %v", err)
+	}
+	if _, err := NewKeySet(oldPublic); err == nil {
+		t.Fatal("Expected a public key to be refused as the signing key")
+	}
+	newSet, err := NewKeySet(newKey, oldPublic)
+	if err != nil {
+		t.Fatalf("NewKeySet failed: %v", err)
+	}
+	newToken, err := newSet.MakeJWT(userID, 0, nil, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+
+	for _, token := range []string{oldToken, newToken} {
+		claims, err := newSet.ParseJWT(token)
+		if err != nil {
+			t.Fatalf("ParseJWT failed: %v", err)
+		}
+		if claims.UserID != userID {
+			t.Fatalf("Expected userID %v, got %v", userID, claims.UserID)
+		}
+	}
+	if _, err := oldSet.ParseJWT(newToken); err == nil {
+		t.Fatal("Expected a token from a key the set doesn't have to be rejected")
+	}
+}
+
+func TestKeySetEnforcesAlgorithm(t *testing.T) {
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	ks, err := NewKeySet(makeKeyPEM(t, "rsa", rsaPriv))
+	if err != nil {
+		t.Fatalf("NewKeySet failed: %v", err)
+	}
+
+	// an HS256 token keyed with the published RSA key must not verify
+	pubDER, _ := x509.MarshalPKIXPublicKey(&rsaPriv.PublicKey)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    TokenIssuer,
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		Subject:   uuid.NewString(),
+	})
+	forged.Header["kid"] = "rsa"
+	forgedToken, err := forged.SignedString(pubDER)
+	if err != nil {
+		t.Fatalf("SignedString failed: %v", err)
+	}
+	if _, err := ks.ParseJWT(forgedToken); err == nil {
+		t.Fatal("Expected a token with the wrong algorithm to be rejected")
+	}
+
+	// the HS256 helpers only accept HS256
+	rsaToken, err := ks.MakeJWT(uuid.New(), 0, nil, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+	if _, err := ValidateJWT(rsaToken, "secret"); err == nil {
+		t.Fatal("Expected ValidateJWT to reject an RS256 token")
+	}
+}
+
+func TestKeySetJWKS(t *testing.T) {
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	ks, err := NewKeySet(makeKeyPEM(t, "b-ed", edPriv), makeKeyPEM(t, "a-rsa", rsaPriv), NewHMACKey("", []byte("secret")))
+	if err != nil {
+		t.Fatalf("NewKeySet failed: %v", err)
+	}
+
+	jwks := ks.JWKS()
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("Expected 2 public keys, got %+v", jwks.Keys)
+	}
+	rsaJWK, edJWK := jwks.Keys[0], jwks.Keys[1]
+	if rsaJWK.ID != "a-rsa" || rsaJWK.KeyType != "RSA" || rsaJWK.Algorithm != "RS256" || rsaJWK.E != "AQAB" {
+		t.Fatalf("Unexpected RSA key: %+v", rsaJWK)
+	}
+	if edJWK.ID != "b-ed" || edJWK.KeyType != "OKP" || edJWK.Curve != "Ed25519" || edJWK.Algorithm != "EdDSA" {
+		t.Fatalf("Unexpected Ed25519 key: %+v", edJWK)
+	}
+	if edJWK.X != base64.RawURLEncoding.EncodeToString(edPub) {
+		t.Fatal("Expected the Ed25519 public key in x")
+	}
+}
+
+func TestKeySetScopes(t *testing.T) {
+	ks, err := NewKeySet(NewHMACKey("hs", []byte("secret")))
+	if err != nil {
+		t.Fatalf("NewKeySet failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		scopes []string
+		want   []string
+	}{
+		{"scoped", []string{ScopeChirpsRead, ScopeChirpsWrite}, []string{ScopeChirpsRead, ScopeChirpsWrite}},
+		{"no scopes", []string{}, []string{}},
+		{"unscoped", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := ks.MakeJWT(uuid.New(), 0, tt.scopes, time.Minute)
+			if err != nil {
+				t.Fatalf("MakeJWT failed: %v", err)
+			}
+			claims, err := ks.ParseJWT(token)
+			if err != nil {
+				t.Fatalf("ParseJWT failed: %v", err)
+			}
+			if (claims.Scopes == nil) != (tt.want == nil) || len(claims.Scopes) != len(tt.want) {
+				t.Fatalf("Expected scopes %#v, got %#v", tt.want, claims.Scopes)
+			}
+			for i := range tt.want {
+				if claims.Scopes[i] != tt.want[i] {
+					t.Fatalf("Expected scopes %#v, got %#v", tt.want, claims.Scopes)
+				}
+			}
+		})
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	key, err := MakeAPIKey()
+	if err != nil {
+		t.Fatalf("MakeAPIKey failed: %v", err)
+	}
+	other, err := MakeAPIKey()
+	if err != nil {
+		t.Fatalf("MakeAPIKey failed: %v", err)
+	}
+	if key == other {
+		t.Fatal("Expected different keys")
+	}
+	if !IsAPIKey(key) {
+		t.Fatalf("Expected %q to be an API key", key)
+	}
+
+	jwtToken, err := MakeJWT(uuid.New(), "secret", time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+	if IsAPIKey(jwtToken) {
+		t.Fatal("JWT should not be an API key")
+	}
+
+	if HashAPIKey(key) != HashAPIKey(key) {
+		t.Fatal("Expected the same hash for the same key")
+	}
+	if HashAPIKey(key) == HashAPIKey(other) {
+		t.Fatal("Expected different hashes for different keys")
+	}
+	if HashAPIKey(key) == key {
+		t.Fatal("Hash should not equal the key")
+	}
+
+	prefix := APIKeyDisplayPrefix(key)
+	if prefix != key[:len(APIKeyPrefix)+6] {
+		t.Fatalf("Unexpected display prefix %q", prefix)
+	}
+}
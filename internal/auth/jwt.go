@@ -1,11 +1,8 @@
 package auth
 
 import (
-	"errors"
-	"fmt"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
@@ -13,40 +10,40 @@ const (
 	TokenIssuer = "chirpy-access"
 )
 
+// AccessClaims are the parts of a validated access token the server acts on.
+type AccessClaims struct {
+	ID           string
+	UserID       uuid.UUID
+	IssuedAt     time.Time
+	TokenVersion int32
+	// Scopes is nil for tokens issued before scopes existed.
+	Scopes []string
+}
+
+// MakeJWT issues an HS256 access token signed with tokenSecret, at token
+// version zero and without scopes.
 func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Issuer:    TokenIssuer,
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
-		Subject:   userID.String(),
-	})
-	signedToken, err := token.SignedString([]byte(tokenSecret))
+	ks, err := NewKeySet(NewHMACKey("", []byte(tokenSecret)))
 	if err != nil {
 		return "", err
 	}
-	return signedToken, nil
+	return ks.MakeJWT(userID, 0, nil, expiresIn)
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	claims := jwt.RegisteredClaims{}
-	token, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (any, error) {
-		return []byte(tokenSecret), nil
-	})
+	claims, err := ParseJWT(tokenString, tokenSecret)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("unable to parse token: %v", err)
-	}
-	if !token.Valid {
-		return uuid.Nil, errors.New("token is invalid")
-	}
-	if claims.Issuer != TokenIssuer {
-		return uuid.Nil, errors.New("issuer is invalid")
+		return uuid.Nil, err
 	}
-	if claims.Subject == "" {
-		return uuid.Nil, errors.New("claims subject is empty")
-	}
-	id, err := uuid.Parse(claims.Subject)
+	return claims.UserID, nil
+}
+
+// ParseJWT validates an HS256 access token the same way as ValidateJWT and
+// returns its claims.
+func ParseJWT(tokenString, tokenSecret string) (AccessClaims, error) {
+	ks, err := NewKeySet(NewHMACKey("", []byte(tokenSecret)))
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("unable to parse id: %v", err)
+		return AccessClaims{}, err
 	}
-	return id, nil
+	return ks.ParseJWT(tokenString)
 }
@@ -0,0 +1,277 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// minRSABits is the smallest RSA key accepted for signing or verifying.
+const minRSABits = 2048
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Key signs or verifies access tokens with a single algorithm. Keys parsed
+// from a public key can only verify.
+type Key struct {
+	ID        string
+	method    jwt.SigningMethod
+	signKey   any
+	verifyKey any
+}
+
+// NewHMACKey returns an HS256 key. HMAC keys are never published in a JWKS.
+func NewHMACKey(id string, secret []byte) *Key {
+	return &Key{
+		ID:        id,
+		method:    jwt.SigningMethodHS256,
+		signKey:   secret,
+		verifyKey: secret,
+	}
+}
+
+// ParseKeyPEM reads a PKCS #8 private key or a PKIX public key. Ed25519 keys
+// use EdDSA and RSA keys use RS256.
+func ParseKeyPEM(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	switch block.Type {
+	case "PRIVATE KEY":
+		priv, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch k := priv.(type) {
+		case ed25519.PrivateKey:
+			return &Key{ID: id, method: jwt.SigningMethodEdDSA, signKey: k, verifyKey: k.Public()}, nil
+		case *rsa.PrivateKey:
+			if k.N.BitLen() < minRSABits {
+				return nil, fmt.Errorf("RSA key must be at least %d bits", minRSABits)
+			}
+			return &Key{ID: id, method: jwt.SigningMethodRS256, signKey: k, verifyKey: &k.PublicKey}, nil
+		}
+		return nil, fmt.Errorf("unsupported private key type %T", priv)
+	case "PUBLIC KEY":
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch k := pub.(type) {
+		case ed25519.PublicKey:
+			return &Key{ID: id, method: jwt.SigningMethodEdDSA, verifyKey: k}, nil
+		case *rsa.PublicKey:
+			if k.N.BitLen() < minRSABits {
+				return nil, fmt.Errorf("RSA key must be at least %d bits", minRSABits)
+			}
+			return &Key{ID: id, method: jwt.SigningMethodRS256, verifyKey: k}, nil
+		}
+		return nil, fmt.Errorf("unsupported public key type %T", pub)
+	}
+	return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+}
+
+// LoadKeyDir reads every .pem file in dir as a key whose ID is the file name
+// without its extension.
+func LoadKeyDir(dir string) ([]*Key, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	keys := []*Key{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		id := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := ParseKeyPEM(id, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// KeySet signs new tokens with one key and accepts tokens from any of its
+// keys, so signing can move to a new key while tokens from the old one are
+// still valid.
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+	methods []string
+}
+
+func NewKeySet(signing *Key, others ...*Key) (*KeySet, error) {
+	if signing == nil || signing.signKey == nil {
+		return nil, errors.New("signing key must have a private key")
+	}
+	ks := &KeySet{
+		signing: signing,
+		keys:    map[string]*Key{},
+	}
+	for _, key := range append([]*Key{signing}, others...) {
+		if _, ok := ks.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key ID %q", key.ID)
+		}
+		ks.keys[key.ID] = key
+		if !slices.Contains(ks.methods, key.method.Alg()) {
+			ks.methods = append(ks.methods, key.method.Alg())
+		}
+	}
+	return ks, nil
+}
+
+// accessTokenClaims adds the user's token version and scopes to the
+// registered claims. Bumping the version in the database retires every token
+// issued before. Scope is a space-separated list, left out of tokens issued
+// before scopes existed.
+type accessTokenClaims struct {
+	jwt.RegisteredClaims
+	TokenVersion int32   `json:"ver"`
+	Scope        *string `json:"scope,omitempty"`
+}
+
+// MakeJWT issues an access token signed with the set's signing key, naming
+// the key in the kid header. Each token gets its own jti. A nil scopes leaves
+// the scope claim out.
+func (ks *KeySet) MakeJWT(userID uuid.UUID, tokenVersion int32, scopes []string, expiresIn time.Duration) (string, error) {
+	var scope *string
+	if scopes != nil {
+		joined := strings.Join(scopes, " ")
+		scope = &joined
+	}
+	token := jwt.NewWithClaims(ks.signing.method, accessTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    TokenIssuer,
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+			Subject:   userID.String(),
+		},
+		TokenVersion: tokenVersion,
+		Scope:        scope,
+	})
+	if ks.signing.ID != "" {
+		token.Header["kid"] = ks.signing.ID
+	}
+	return token.SignedString(ks.signing.signKey)
+}
+
+// ParseJWT validates an access token against the key named by its kid
+// header, and only with that key's algorithm.
+func (ks *KeySet) ParseJWT(tokenString string) (AccessClaims, error) {
+	claims := accessTokenClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, &claims, ks.keyfunc, jwt.WithValidMethods(ks.methods))
+	if err != nil {
+		return AccessClaims{}, fmt.Errorf("unable to parse token: %v", err)
+	}
+	if !token.Valid {
+		return AccessClaims{}, errors.New("token is invalid")
+	}
+	if claims.Issuer != TokenIssuer {
+		return AccessClaims{}, errors.New("issuer is invalid")
+	}
+	if claims.Subject == "" {
+		return AccessClaims{}, errors.New("claims subject is empty")
+	}
+	if claims.IssuedAt == nil {
+		return AccessClaims{}, errors.New("claims issued at is missing")
+	}
+	id, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return AccessClaims{}, fmt.Errorf("unable to parse id: %v", err)
+	}
+	var scopes []string
+	if claims.Scope != nil {
+		scopes = strings.Fields(*claims.Scope)
+		if scopes == nil {
+			scopes = []string{}
+		}
+	}
+	return AccessClaims{
+		ID:           claims.ID,
+		UserID:       id,
+		IssuedAt:     claims.IssuedAt.Time,
+		TokenVersion: claims.TokenVersion,
+		Scopes:       scopes,
+	}, nil
+}
+
+func (ks *KeySet) keyfunc(token *jwt.Token) (any, error) {
+	kid := ""
+	if header, ok := token.Header["kid"]; ok {
+		if kid, ok = header.(string); !ok {
+			return nil, errors.New("kid header must be a string")
+		}
+	}
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s for key %q", token.Method.Alg(), kid)
+	}
+	return key.verifyKey, nil
+}
+
+// JWK is a public key in JSON Web Key form.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	ID        string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the set's public keys, sorted by ID, for other services to
+// verify tokens with. HMAC keys are secret and left out.
+func (ks *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range ks.keys {
+		jwk := JWK{
+			ID:        key.ID,
+			Use:       "sig",
+			Algorithm: key.method.Alg(),
+		}
+		switch k := key.verifyKey.(type) {
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(k)
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(k.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+		default:
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	slices.SortFunc(jwks.Keys, func(a, b JWK) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return jwks
+}
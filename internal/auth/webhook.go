@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+)
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// SignWebhook returns the hex encoded HMAC-SHA256 of timestamp, a dot and
+// body, keyed with secret.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks that signature was made by SignWebhook with one of
+// secrets, so a secret can be rotated while the old one still verifies, and
+// that timestamp, in Unix seconds, is within tolerance of now.
+func VerifyWebhook(secrets []string, timestamp, signature string, body []byte, now time.Time, tolerance time.Duration) error {
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	age := now.Sub(time.Unix(unix, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrInvalidSignature
+	}
+
+	given, err := hex.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		expected, _ := hex.DecodeString(SignWebhook(secret, timestamp, body))
+		if hmac.Equal(given, expected) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
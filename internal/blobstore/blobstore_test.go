@@ -0,0 +1,59 @@
+package blobstore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorePutAndDelete(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewLocalStore(root, "/app/uploads/")
+	if err != nil {
+		t.Fatalf("NewLocalStore failed: %v", err)
+	}
+
+	ctx := context.Background()
+	key := "chirps/abc/image.png"
+	err = store.Put(ctx, key, strings.NewReader("data"))
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, "chirps", "abc", "image.png"))
+	if err != nil {
+		t.Fatalf("Expected blob on disk: %v", err)
+	}
+	if string(data) != "data" {
+		t.Fatalf("Expected blob contents %q, got %q", "data", data)
+	}
+
+	if url := store.URL(key); url != "/app/uploads/chirps/abc/image.png" {
+		t.Fatalf("Unexpected URL %q", url)
+	}
+
+	err = store.Delete(ctx, key)
+	if err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	err = store.Delete(ctx, key)
+	if err != nil {
+		t.Fatalf("Deleting a missing blob should succeed, got %v", err)
+	}
+}
+
+func TestLocalStoreRejectsEscapingKeys(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir(), "/uploads")
+	if err != nil {
+		t.Fatalf("NewLocalStore failed: %v", err)
+	}
+
+	for _, key := range []string{"", "../secret", "/etc/passwd", "a/../../b"} {
+		err := store.Put(context.Background(), key, strings.NewReader("x"))
+		if err != ErrInvalidKey {
+			t.Fatalf("Put(%q): expected ErrInvalidKey, got %v", key, err)
+		}
+	}
+}
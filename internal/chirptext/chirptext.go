@@ -0,0 +1,92 @@
+package chirptext
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+// URLWeight is how many characters a URL counts for, however long it is.
+const URLWeight = 23
+
+var urlPattern = regexp.MustCompile(`(?i)\bhttps?://\S+`)
+
+var ErrInvalidText = errors.New("invalid text")
+
+// LengthError reports text over its limit, measured the same way as Length.
+type LengthError struct {
+	Length int
+	Max    int
+}
+
+func (e *LengthError) Error() string {
+	return fmt.Sprintf("chirp is %d characters long; the limit is %d", e.Length, e.Max)
+}
+
+// Normalize returns text in Unicode normalization form C, so the same
+// visible text is always stored the same way.
+func Normalize(text string) string {
+	return norm.NFC.String(text)
+}
+
+// Length counts what a reader would see as characters: each grapheme
+// cluster counts once, so an emoji or an accented letter is a single
+// character, and each URL counts as URLWeight.
+func Length(text string) int {
+	length := 0
+	last := 0
+	for _, loc := range urlPattern.FindAllStringIndex(text, -1) {
+		length += uniseg.GraphemeClusterCount(text[last:loc[0]]) + URLWeight
+		last = loc[1]
+	}
+	return length + uniseg.GraphemeClusterCount(text[last:])
+}
+
+// Check normalizes text and makes sure it has no control characters other
+// than ordinary whitespace, no stray zero-width characters and is at most
+// maxLength characters long. It returns the normalized text.
+func Check(text string, maxLength int) (string, error) {
+	if !utf8.ValidString(text) {
+		return "", fmt.Errorf("%w: not valid UTF-8", ErrInvalidText)
+	}
+	text = Normalize(text)
+
+	runes := []rune(text)
+	for i, r := range runes {
+		switch {
+		case r == '\n' || r == '\r' || r == '\t':
+		case unicode.IsControl(r):
+			return "", fmt.Errorf("%w: control characters are not allowed", ErrInvalidText)
+		case r == '\u200c' || r == '\u200d':
+			// joiners are allowed where they join two visible characters,
+			// as in emoji sequences and some scripts
+			if i == 0 || i == len(runes)-1 || !isJoinable(runes[i-1]) || !isJoinable(runes[i+1]) {
+				return "", fmt.Errorf("%w: zero-width characters are not allowed", ErrInvalidText)
+			}
+		case isZeroWidth(r):
+			return "", fmt.Errorf("%w: zero-width characters are not allowed", ErrInvalidText)
+		}
+	}
+
+	if length := Length(text); length > maxLength {
+		return "", &LengthError{Length: length, Max: maxLength}
+	}
+	return text, nil
+}
+
+func isZeroWidth(r rune) bool {
+	switch r {
+	case '\u200b', '\u2060', '\ufeff', '\u180e':
+		return true
+	}
+	return false
+}
+
+func isJoinable(r rune) bool {
+	return !unicode.IsSpace(r) && !unicode.IsControl(r) && !isZeroWidth(r) && r != '\u200c' && r != '\u200d'
+}
@@ -0,0 +1,84 @@
+package chirptext
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLength(t *testing.T) {
+	cases := []struct {
+		text     string
+		expected int
+	}{
+		{"hello", 5},
+		{"h\u00e9llo", 5},
+		{"he\u0301llo", 5},
+		{"こんにちは", 5},
+		{"👍🏽👨\u200d👩\u200d👧🇯🇵", 3},
+		{"see https://example.com/a/very/long/path?with=query", 4 + URLWeight},
+		{"http://a.io and HTTPS://b.io", 2*URLWeight + 5},
+	}
+
+	for _, c := range cases {
+		if got := Length(c.text); got != c.expected {
+			t.Fatalf("Length(%q): expected %d, got %d", c.text, c.expected, got)
+		}
+	}
+}
+
+func TestCheckNormalizes(t *testing.T) {
+	got, err := Check("cafe\u0301", 140)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if got != "caf\u00e9" {
+		t.Fatalf("Expected NFC text %q, got %q", "caf\u00e9", got)
+	}
+}
+
+func TestCheckLength(t *testing.T) {
+	if _, err := Check(strings.Repeat("🐦", 140), 140); err != nil {
+		t.Fatalf("Expected 140 emoji to fit, got %v", err)
+	}
+
+	_, err := Check(strings.Repeat("a", 141), 140)
+	var lengthErr *LengthError
+	if !errors.As(err, &lengthErr) || lengthErr.Length != 141 || lengthErr.Max != 140 {
+		t.Fatalf("Expected a length error for 141 of 140, got %v", err)
+	}
+	if lengthErr.Error() != "chirp is 141 characters long; the limit is 140" {
+		t.Fatalf("Unexpected length error message: %q", lengthErr.Error())
+	}
+}
+
+func TestCheckCharacters(t *testing.T) {
+	valid := []string{
+		"line one\nline two",
+		"line one\r\nline two",
+		"tab\there",
+		"👨\u200d👩\u200d👧 family",
+		"می\u200cخواهم",
+	}
+	for _, text := range valid {
+		if _, err := Check(text, 140); err != nil {
+			t.Fatalf("Expected %q to be valid, got %v", text, err)
+		}
+	}
+
+	invalid := []string{
+		"bell\a",
+		"escape\x1b[0m",
+		"hidden\u200bspace",
+		"bom\ufeff",
+		"dangling joiner\u200d",
+		"\u200djoiner first",
+		"word \u200c word",
+		"bad utf8 \xff",
+	}
+	for _, text := range invalid {
+		if _, err := Check(text, 140); !errors.Is(err, ErrInvalidText) {
+			t.Fatalf("Expected %q to be invalid, got %v", text, err)
+		}
+	}
+}
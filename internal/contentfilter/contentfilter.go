@@ -0,0 +1,268 @@
+package contentfilter
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"sync/atomic"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// MatchType says how a rule's pattern is compared against text.
+type MatchType string
+
+const (
+	// MatchWord matches the pattern as a whole word, ignoring case.
+	MatchWord MatchType = "word"
+	// MatchSubstring matches the pattern anywhere, ignoring case.
+	MatchSubstring MatchType = "substring"
+	// MatchRegex matches the pattern as a Go regular expression.
+	MatchRegex MatchType = "regex"
+)
+
+// Action says what happens to text that matches a rule.
+type Action string
+
+const (
+	// ActionReplace swaps each match for the rule's replacement.
+	ActionReplace Action = "replace"
+	// ActionReject refuses the text outright.
+	ActionReject Action = "reject"
+	// ActionFlag lets the text through but reports the rule.
+	ActionFlag Action = "flag"
+)
+
+// DefaultReplacement is used by replace rules without a replacement of their own.
+const DefaultReplacement = "****"
+
+var ErrInvalidRule = errors.New("invalid content rule")
+
+type Rule struct {
+	ID          uuid.UUID
+	Pattern     string
+	Match       MatchType
+	Action      Action
+	Replacement string
+}
+
+// Result is the outcome of filtering one piece of text. Text holds the text
+// after replacements and Flagged the IDs of flag rules that matched.
+type Result struct {
+	Text     string
+	Rejected bool
+	Flagged  []uuid.UUID
+}
+
+type ContentFilter interface {
+	Filter(text string) Result
+}
+
+type compiledRule struct {
+	Rule
+	find func(text string) [][]int
+}
+
+// RuleFilter applies rules in order, each to the output of the one before.
+// Reject rules are checked against the original text first, so a
+// replacement can't hide what they look for.
+type RuleFilter struct {
+	rules []compiledRule
+}
+
+// New compiles rules into a filter, failing on the first invalid rule.
+func New(rules []Rule) (*RuleFilter, error) {
+	f := &RuleFilter{}
+	for _, rule := range rules {
+		compiled, err := compile(rule)
+		if err != nil {
+			return nil, err
+		}
+		f.rules = append(f.rules, compiled)
+	}
+	return f, nil
+}
+
+// Validate reports whether rule would compile.
+func Validate(rule Rule) error {
+	_, err := compile(rule)
+	return err
+}
+
+func compile(rule Rule) (compiledRule, error) {
+	compiled := compiledRule{Rule: rule}
+	if rule.Pattern == "" {
+		return compiled, fmt.Errorf("%w: pattern cannot be empty", ErrInvalidRule)
+	}
+	switch rule.Action {
+	case ActionReplace:
+		if compiled.Replacement == "" {
+			compiled.Replacement = DefaultReplacement
+		}
+	case ActionReject, ActionFlag:
+	default:
+		return compiled, fmt.Errorf("%w: unknown action %q", ErrInvalidRule, rule.Action)
+	}
+
+	switch rule.Match {
+	case MatchWord:
+		for _, r := range rule.Pattern {
+			if !isWordRune(r) {
+				return compiled, fmt.Errorf("%w: word patterns can only contain letters and digits", ErrInvalidRule)
+			}
+		}
+		compiled.find = func(text string) [][]int {
+			return findWord(text, rule.Pattern)
+		}
+	case MatchSubstring:
+		re := regexp.MustCompile("(?i)" + regexp.QuoteMeta(rule.Pattern))
+		compiled.find = func(text string) [][]int {
+			return re.FindAllStringIndex(text, -1)
+		}
+	case MatchRegex:
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return compiled, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+		}
+		// an empty match would fire on every text, between every character;
+		// assertions such as \b only match empty spans, so check the
+		// pattern's shape rather than one sample text
+		parsed, err := syntax.Parse(rule.Pattern, syntax.Perl)
+		if err != nil {
+			return compiled, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+		}
+		if canMatchEmpty(parsed.Simplify()) {
+			return compiled, fmt.Errorf("%w: pattern cannot match empty text", ErrInvalidRule)
+		}
+		compiled.find = func(text string) [][]int {
+			return re.FindAllStringIndex(text, -1)
+		}
+	default:
+		return compiled, fmt.Errorf("%w: unknown match type %q", ErrInvalidRule, rule.Match)
+	}
+	return compiled, nil
+}
+
+// canMatchEmpty reports whether re can match a zero-length span of some
+// text. Empty-width assertions are assumed to hold somewhere.
+func canMatchEmpty(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine,
+		syntax.OpBeginText, syntax.OpEndText,
+		syntax.OpWordBoundary, syntax.OpNoWordBoundary,
+		syntax.OpStar, syntax.OpQuest:
+		return true
+	case syntax.OpLiteral:
+		return len(re.Rune) == 0
+	case syntax.OpCapture, syntax.OpPlus:
+		return canMatchEmpty(re.Sub[0])
+	case syntax.OpRepeat:
+		return re.Min == 0 || canMatchEmpty(re.Sub[0])
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if !canMatchEmpty(sub) {
+				return false
+			}
+		}
+		return true
+	case syntax.OpAlternate:
+		for _, sub := range re.Sub {
+			if canMatchEmpty(sub) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// Filter runs text through every rule, stopping early if one rejects it.
+func (f *RuleFilter) Filter(text string) Result {
+	result := Result{Text: text, Flagged: []uuid.UUID{}}
+	for _, rule := range f.rules {
+		if rule.Action == ActionReject && len(rule.find(text)) > 0 {
+			result.Rejected = true
+			return result
+		}
+	}
+	for _, rule := range f.rules {
+		if rule.Action == ActionReject {
+			continue
+		}
+		matches := rule.find(result.Text)
+		if len(matches) == 0 {
+			continue
+		}
+		switch rule.Action {
+		case ActionFlag:
+			result.Flagged = append(result.Flagged, rule.ID)
+		case ActionReplace:
+			result.Text = replace(result.Text, matches, rule.Replacement)
+		}
+	}
+	return result
+}
+
+// Reloadable is a ContentFilter whose rules can be swapped while it is in
+// use. Until rules are first loaded it lets everything through.
+type Reloadable struct {
+	current atomic.Pointer[RuleFilter]
+}
+
+// Reload compiles rules and swaps them in. If any rule is invalid the
+// previous rules stay in place.
+func (r *Reloadable) Reload(rules []Rule) error {
+	f, err := New(rules)
+	if err != nil {
+		return err
+	}
+	r.current.Store(f)
+	return nil
+}
+
+func (r *Reloadable) Filter(text string) Result {
+	f := r.current.Load()
+	if f == nil {
+		return Result{Text: text, Flagged: []uuid.UUID{}}
+	}
+	return f.Filter(text)
+}
+
+// words are runs of letters, digits and combining marks, so surrounding
+// punctuation and any kind of whitespace separate them
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
+
+func findWord(text, word string) [][]int {
+	matches := [][]int{}
+	start := -1
+	for i, r := range text + " " {
+		if i < len(text) && isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 && strings.EqualFold(text[start:i], word) {
+			matches = append(matches, []int{start, i})
+		}
+		start = -1
+	}
+	return matches
+}
+
+func replace(text string, matches [][]int, replacement string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(text[last:m[0]])
+		b.WriteString(replacement)
+		last = m[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
@@ -0,0 +1,137 @@
+package contentfilter
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestFilterReplacesWholeWords(t *testing.T) {
+	f, err := New([]Rule{
+		{Pattern: "kerfuffle", Match: MatchWord, Action: ActionReplace},
+		{Pattern: "fornax", Match: MatchWord, Action: ActionReplace, Replacement: "[redacted]"},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	cases := []struct {
+		text     string
+		expected string
+	}{
+		{"what a Kerfuffle!", "what a ****!"},
+		{"kerfuffle,kerfuffle\tkerfuffle", "****,****\t****"},
+		{"kerfuffled is a different word", "kerfuffled is a different word"},
+		{"fornax\nfornax", "[redacted]\n[redacted]"},
+		{"nothing to see", "nothing to see"},
+	}
+	for _, c := range cases {
+		got := f.Filter(c.text)
+		if got.Rejected || got.Text != c.expected {
+			t.Fatalf("Filter(%q): expected %q, got %q (rejected: %v)", c.text, c.expected, got.Text, got.Rejected)
+		}
+	}
+}
+
+func TestFilterSubstringAndRegex(t *testing.T) {
+	f, err := New([]Rule{
+		{Pattern: "harb", Match: MatchSubstring, Action: ActionReplace, Replacement: "-"},
+		{Pattern: `\d{3}-\d{4}`, Match: MatchRegex, Action: ActionReplace, Replacement: "###"},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	got := f.Filter("SHARBERT, call 555-1234")
+	if got.Text != "S-ERT, call ###" {
+		t.Fatalf("Expected substring and regex replacements, got %q", got.Text)
+	}
+}
+
+func TestFilterRejectAndFlag(t *testing.T) {
+	flagID := uuid.New()
+	f, err := New([]Rule{
+		{ID: flagID, Pattern: "spoiler", Match: MatchWord, Action: ActionFlag},
+		{Pattern: "scam", Match: MatchSubstring, Action: ActionReject},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	got := f.Filter("Spoiler: it was fine")
+	if got.Rejected || !slices.Equal(got.Flagged, []uuid.UUID{flagID}) || got.Text != "Spoiler: it was fine" {
+		t.Fatalf("Expected flagged but unchanged text, got %+v", got)
+	}
+	if got := f.Filter("totally not a SCAMMER"); !got.Rejected {
+		t.Fatalf("Expected text to be rejected, got %+v", got)
+	}
+}
+
+func TestFilterRejectsBeforeReplacing(t *testing.T) {
+	f, err := New([]Rule{
+		{Pattern: "scam", Match: MatchWord, Action: ActionReplace, Replacement: "deal"},
+		{Pattern: "scam", Match: MatchWord, Action: ActionReject},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if got := f.Filter("a great scam"); !got.Rejected {
+		t.Fatalf("Expected text to be rejected, got %+v", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	invalid := []Rule{
+		{Pattern: "", Match: MatchWord, Action: ActionReplace},
+		{Pattern: "two words", Match: MatchWord, Action: ActionReplace},
+		{Pattern: "([", Match: MatchRegex, Action: ActionReject},
+		{Pattern: "x", Match: "glob", Action: ActionReject},
+		{Pattern: "x", Match: MatchWord, Action: "delete"},
+		{Pattern: "a*", Match: MatchRegex, Action: ActionReplace},
+		{Pattern: "x?", Match: MatchRegex, Action: ActionFlag},
+		{Pattern: `\b`, Match: MatchRegex, Action: ActionFlag},
+		{Pattern: `^|x`, Match: MatchRegex, Action: ActionReplace},
+		{Pattern: `(?:x*\B)+`, Match: MatchRegex, Action: ActionReject},
+	}
+	for _, rule := range invalid {
+		if err := Validate(rule); !errors.Is(err, ErrInvalidRule) {
+			t.Fatalf("Expected %+v to be invalid, got %v", rule, err)
+		}
+	}
+
+	valid := []Rule{
+		{Pattern: `\bcat\b`, Match: MatchRegex, Action: ActionReplace},
+		{Pattern: `^x+$`, Match: MatchRegex, Action: ActionReject},
+		{Pattern: `a{0,2}b`, Match: MatchRegex, Action: ActionFlag},
+	}
+	for _, rule := range valid {
+		if err := Validate(rule); err != nil {
+			t.Fatalf("Expected %+v to be valid, got %v", rule, err)
+		}
+	}
+}
+
+func TestReloadable(t *testing.T) {
+	var r Reloadable
+	if got := r.Filter("kerfuffle"); got.Text != "kerfuffle" {
+		t.Fatalf("Expected empty filter to pass text through, got %q", got.Text)
+	}
+
+	err := r.Reload([]Rule{{Pattern: "kerfuffle", Match: MatchWord, Action: ActionReplace}})
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := r.Filter("kerfuffle"); got.Text != "****" {
+		t.Fatalf("Expected reloaded rule to apply, got %q", got.Text)
+	}
+
+	err = r.Reload([]Rule{{Pattern: "([", Match: MatchRegex, Action: ActionReject}})
+	if err == nil {
+		t.Fatal("Expected invalid rules to fail to reload")
+	}
+	if got := r.Filter("kerfuffle"); got.Text != "****" {
+		t.Fatalf("Expected previous rules to stay in place, got %q", got.Text)
+	}
+}
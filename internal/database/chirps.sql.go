@@ -7,29 +7,50 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, parent_chirp_id, kind, referenced_chirp_id, published, publish_at)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, search_vector, parent_chirp_id, deleted_at, like_count, kind, referenced_chirp_id, published, publish_at, scrubbed_at
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body              string
+	UserID            uuid.UUID
+	ParentChirpID     uuid.NullUUID
+	Kind              string
+	ReferencedChirpID uuid.NullUUID
+	Published         bool
+	PublishAt         sql.NullTime
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.ParentChirpID,
+		arg.Kind,
+		arg.ReferencedChirpID,
+		arg.Published,
+		arg.PublishAt,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,6 +58,15 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.SearchVector,
+		&i.ParentChirpID,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.Kind,
+		&i.ReferencedChirpID,
+		&i.Published,
+		&i.PublishAt,
+		&i.ScrubbedAt,
 	)
 	return i, err
 }
@@ -52,8 +82,10 @@ func (q *Queries) DeleteAChirp(ctx context.Context, id uuid.UUID) error {
 }
 
 const getAChirp = `-- name: GetAChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, search_vector, parent_chirp_id, deleted_at, like_count, kind, referenced_chirp_id, published, publish_at, scrubbed_at FROM chirps
 WHERE id = $1
+AND deleted_at IS NULL
+AND published
 `
 
 func (q *Queries) GetAChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -65,17 +97,130 @@ func (q *Queries) GetAChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.SearchVector,
+		&i.ParentChirpID,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.Kind,
+		&i.ReferencedChirpID,
+		&i.Published,
+		&i.PublishAt,
+		&i.ScrubbedAt,
+	)
+	return i, err
+}
+
+const getAChirpForUpdate = `-- name: GetAChirpForUpdate :one
+SELECT id, created_at, updated_at, body, user_id, search_vector, parent_chirp_id, deleted_at, like_count, kind, referenced_chirp_id, published, publish_at, scrubbed_at FROM chirps
+WHERE id = $1
+AND deleted_at IS NULL
+FOR UPDATE
+`
+
+func (q *Queries) GetAChirpForUpdate(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getAChirpForUpdate, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.SearchVector,
+		&i.ParentChirpID,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.Kind,
+		&i.ReferencedChirpID,
+		&i.Published,
+		&i.PublishAt,
+		&i.ScrubbedAt,
 	)
 	return i, err
 }
 
-const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-ORDER BY created_at ASC
+const getChirpAncestors = `-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors AS (
+    SELECT c.id, c.parent_chirp_id, 1 AS depth FROM chirps c
+    WHERE c.id = (SELECT p.parent_chirp_id FROM chirps p WHERE p.id = $1::uuid)
+    UNION ALL
+    SELECT c.id, c.parent_chirp_id, a.depth + 1 FROM chirps c
+    JOIN ancestors a ON c.id = a.parent_chirp_id
+    WHERE a.depth < $2::int
+)
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.search_vector, chirps.parent_chirp_id, chirps.deleted_at, chirps.like_count, chirps.kind, chirps.referenced_chirp_id, chirps.published, chirps.publish_at, chirps.scrubbed_at FROM chirps
+JOIN ancestors ON chirps.id = ancestors.id
+ORDER BY ancestors.depth DESC
+`
+
+type GetChirpAncestorsParams struct {
+	ChirpID  uuid.UUID
+	MaxDepth int32
+}
+
+func (q *Queries) GetChirpAncestors(ctx context.Context, arg GetChirpAncestorsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpAncestors, arg.ChirpID, arg.MaxDepth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.ParentChirpID,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.Kind,
+			&i.ReferencedChirpID,
+			&i.Published,
+			&i.PublishAt,
+			&i.ScrubbedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpDescendants = `-- name: GetChirpDescendants :many
+WITH RECURSIVE descendants AS (
+    SELECT c.id, 1 AS depth FROM chirps c
+    WHERE c.parent_chirp_id = $1::uuid
+    AND c.published
+    UNION ALL
+    SELECT c.id, d.depth + 1 FROM chirps c
+    JOIN descendants d ON c.parent_chirp_id = d.id
+    WHERE d.depth < $2::int
+    AND c.published
+)
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.search_vector, chirps.parent_chirp_id, chirps.deleted_at, chirps.like_count, chirps.kind, chirps.referenced_chirp_id, chirps.published, chirps.publish_at, chirps.scrubbed_at FROM chirps
+JOIN descendants ON chirps.id = descendants.id
+ORDER BY chirps.created_at ASC
+LIMIT $3
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+type GetChirpDescendantsParams struct {
+	ChirpID  uuid.UUID
+	MaxDepth int32
+	Limit    int32
+}
+
+func (q *Queries) GetChirpDescendants(ctx context.Context, arg GetChirpDescendantsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpDescendants, arg.ChirpID, arg.MaxDepth, arg.Limit)
 	if err != nil {
 		return nil, err
 	}
@@ -89,6 +234,15 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.SearchVector,
+			&i.ParentChirpID,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.Kind,
+			&i.ReferencedChirpID,
+			&i.Published,
+			&i.PublishAt,
+			&i.ScrubbedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -102,3 +256,639 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 	}
 	return items, nil
 }
+
+const getChirpsByIDs = `-- name: GetChirpsByIDs :many
+SELECT id, created_at, updated_at, body, user_id, search_vector, parent_chirp_id, deleted_at, like_count, kind, referenced_chirp_id, published, publish_at, scrubbed_at FROM chirps
+WHERE id = ANY($1::uuid[])
+`
+
+func (q *Queries) GetChirpsByIDs(ctx context.Context, ids []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.ParentChirpID,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.Kind,
+			&i.ReferencedChirpID,
+			&i.Published,
+			&i.PublishAt,
+			&i.ScrubbedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsPageAsc = `-- name: GetChirpsPageAsc :many
+SELECT id, created_at, updated_at, body, user_id, search_vector, parent_chirp_id, deleted_at, like_count, kind, referenced_chirp_id, published, publish_at, scrubbed_at FROM chirps
+WHERE deleted_at IS NULL
+AND published
+AND ($1::uuid IS NULL OR user_id = $1::uuid)
+AND ($2::timestamp IS NULL
+    OR (created_at, id) > ($2::timestamp, $3::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT $4
+`
+
+type GetChirpsPageAscParams struct {
+	AuthorID       uuid.NullUUID
+	AfterCreatedAt sql.NullTime
+	AfterID        uuid.NullUUID
+	Limit          int32
+}
+
+func (q *Queries) GetChirpsPageAsc(ctx context.Context, arg GetChirpsPageAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsPageAsc,
+		arg.AuthorID,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.ParentChirpID,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.Kind,
+			&i.ReferencedChirpID,
+			&i.Published,
+			&i.PublishAt,
+			&i.ScrubbedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsPageDesc = `-- name: GetChirpsPageDesc :many
+SELECT id, created_at, updated_at, body, user_id, search_vector, parent_chirp_id, deleted_at, like_count, kind, referenced_chirp_id, published, publish_at, scrubbed_at FROM chirps
+WHERE deleted_at IS NULL
+AND published
+AND ($1::uuid IS NULL OR user_id = $1::uuid)
+AND ($2::timestamp IS NULL
+    OR (created_at, id) < ($2::timestamp, $3::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT $4
+`
+
+type GetChirpsPageDescParams struct {
+	AuthorID       uuid.NullUUID
+	AfterCreatedAt sql.NullTime
+	AfterID        uuid.NullUUID
+	Limit          int32
+}
+
+func (q *Queries) GetChirpsPageDesc(ctx context.Context, arg GetChirpsPageDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsPageDesc,
+		arg.AuthorID,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.ParentChirpID,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.Kind,
+			&i.ReferencedChirpID,
+			&i.Published,
+			&i.PublishAt,
+			&i.ScrubbedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDeletedChirpForUpdate = `-- name: GetDeletedChirpForUpdate :one
+SELECT id, created_at, updated_at, body, user_id, search_vector, parent_chirp_id, deleted_at, like_count, kind, referenced_chirp_id, published, publish_at, scrubbed_at FROM chirps
+WHERE id = $1
+AND deleted_at IS NOT NULL
+AND scrubbed_at IS NULL
+FOR UPDATE
+`
+
+func (q *Queries) GetDeletedChirpForUpdate(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getDeletedChirpForUpdate, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.SearchVector,
+		&i.ParentChirpID,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.Kind,
+		&i.ReferencedChirpID,
+		&i.Published,
+		&i.PublishAt,
+		&i.ScrubbedAt,
+	)
+	return i, err
+}
+
+const getScheduledChirps = `-- name: GetScheduledChirps :many
+SELECT id, created_at, updated_at, body, user_id, search_vector, parent_chirp_id, deleted_at, like_count, kind, referenced_chirp_id, published, publish_at, scrubbed_at FROM chirps
+WHERE user_id = $1
+AND NOT published
+AND deleted_at IS NULL
+ORDER BY publish_at ASC
+`
+
+func (q *Queries) GetScheduledChirps(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getScheduledChirps, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.ParentChirpID,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.Kind,
+			&i.ReferencedChirpID,
+			&i.Published,
+			&i.PublishAt,
+			&i.ScrubbedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getThreadRoot = `-- name: GetThreadRoot :one
+SELECT id, created_at, updated_at, body, user_id, search_vector, parent_chirp_id, deleted_at, like_count, kind, referenced_chirp_id, published, publish_at, scrubbed_at FROM chirps
+WHERE id = $1
+AND published
+`
+
+func (q *Queries) GetThreadRoot(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getThreadRoot, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.SearchVector,
+		&i.ParentChirpID,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.Kind,
+		&i.ReferencedChirpID,
+		&i.Published,
+		&i.PublishAt,
+		&i.ScrubbedAt,
+	)
+	return i, err
+}
+
+const publishDueChirps = `-- name: PublishDueChirps :many
+WITH published_chirps AS (
+    UPDATE chirps
+    SET published = true, created_at = NOW(), updated_at = NOW()
+    WHERE NOT published
+    AND publish_at <= NOW()
+    AND deleted_at IS NULL
+    RETURNING id, created_at
+), moved_hashtags AS (
+    UPDATE chirp_hashtags
+    SET created_at = published_chirps.created_at
+    FROM published_chirps
+    WHERE chirp_hashtags.chirp_id = published_chirps.id
+), moved_mentions AS (
+    UPDATE mentions
+    SET created_at = published_chirps.created_at
+    FROM published_chirps
+    WHERE mentions.chirp_id = published_chirps.id
+)
+SELECT id FROM published_chirps
+`
+
+func (q *Queries) PublishDueChirps(ctx context.Context) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, publishDueChirps)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeDeletedChirps = `-- name: PurgeDeletedChirps :many
+WITH purged AS (
+    DELETE FROM chirps
+    WHERE deleted_at < $1::timestamp
+    AND NOT EXISTS (
+        SELECT 1 FROM chirps replies
+        WHERE replies.parent_chirp_id = chirps.id
+    )
+    RETURNING id
+)
+SELECT chirp_attachments.id, chirp_attachments.created_at, chirp_attachments.chirp_id, chirp_attachments.blob_key, chirp_attachments.content_type, chirp_attachments.width, chirp_attachments.height, chirp_attachments.alt_text, chirp_attachments.position FROM chirp_attachments
+JOIN purged ON chirp_attachments.chirp_id = purged.id
+`
+
+func (q *Queries) PurgeDeletedChirps(ctx context.Context, deletedBefore time.Time) ([]ChirpAttachment, error) {
+	rows, err := q.db.QueryContext(ctx, purgeDeletedChirps, deletedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ChirpAttachment
+	for rows.Next() {
+		var i ChirpAttachment
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.ChirpID,
+			&i.BlobKey,
+			&i.ContentType,
+			&i.Width,
+			&i.Height,
+			&i.AltText,
+			&i.Position,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const rescheduleChirp = `-- name: RescheduleChirp :one
+UPDATE chirps
+SET publish_at = $2, updated_at = NOW()
+WHERE id = $1
+AND NOT published
+RETURNING id, created_at, updated_at, body, user_id, search_vector, parent_chirp_id, deleted_at, like_count, kind, referenced_chirp_id, published, publish_at, scrubbed_at
+`
+
+type RescheduleChirpParams struct {
+	ID        uuid.UUID
+	PublishAt sql.NullTime
+}
+
+func (q *Queries) RescheduleChirp(ctx context.Context, arg RescheduleChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, rescheduleChirp, arg.ID, arg.PublishAt)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.SearchVector,
+		&i.ParentChirpID,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.Kind,
+		&i.ReferencedChirpID,
+		&i.Published,
+		&i.PublishAt,
+		&i.ScrubbedAt,
+	)
+	return i, err
+}
+
+const restoreChirp = `-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, search_vector, parent_chirp_id, deleted_at, like_count, kind, referenced_chirp_id, published, publish_at, scrubbed_at
+`
+
+func (q *Queries) RestoreChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, restoreChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.SearchVector,
+		&i.ParentChirpID,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.Kind,
+		&i.ReferencedChirpID,
+		&i.Published,
+		&i.PublishAt,
+		&i.ScrubbedAt,
+	)
+	return i, err
+}
+
+const restoreRechirpsOf = `-- name: RestoreRechirpsOf :exec
+UPDATE chirps
+SET deleted_at = NULL
+WHERE referenced_chirp_id = $1::uuid
+AND kind = 'rechirp'
+AND deleted_at = $2
+`
+
+type RestoreRechirpsOfParams struct {
+	ChirpID   uuid.UUID
+	DeletedAt sql.NullTime
+}
+
+func (q *Queries) RestoreRechirpsOf(ctx context.Context, arg RestoreRechirpsOfParams) error {
+	_, err := q.db.ExecContext(ctx, restoreRechirpsOf, arg.ChirpID, arg.DeletedAt)
+	return err
+}
+
+const scrubDeletedChirps = `-- name: ScrubDeletedChirps :many
+WITH scrubbed AS (
+    UPDATE chirps
+    SET body = '', scrubbed_at = NOW()
+    WHERE deleted_at < $1::timestamp
+    AND scrubbed_at IS NULL
+    RETURNING id
+), removed_revisions AS (
+    DELETE FROM chirp_revisions
+    USING scrubbed
+    WHERE chirp_revisions.chirp_id = scrubbed.id
+)
+DELETE FROM chirp_attachments
+USING scrubbed
+WHERE chirp_attachments.chirp_id = scrubbed.id
+RETURNING chirp_attachments.id, chirp_attachments.created_at, chirp_attachments.chirp_id, chirp_attachments.blob_key, chirp_attachments.content_type, chirp_attachments.width, chirp_attachments.height, chirp_attachments.alt_text, chirp_attachments.position
+`
+
+func (q *Queries) ScrubDeletedChirps(ctx context.Context, deletedBefore time.Time) ([]ChirpAttachment, error) {
+	rows, err := q.db.QueryContext(ctx, scrubDeletedChirps, deletedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ChirpAttachment
+	for rows.Next() {
+		var i ChirpAttachment
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.ChirpID,
+			&i.BlobKey,
+			&i.ContentType,
+			&i.Width,
+			&i.Height,
+			&i.AltText,
+			&i.Position,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchChirps = `-- name: SearchChirps :many
+SELECT id, created_at, updated_at, body, user_id, search_vector, parent_chirp_id, deleted_at, like_count, kind, referenced_chirp_id, published, publish_at, scrubbed_at FROM chirps
+WHERE search_vector @@ to_tsquery('english', $1)
+AND deleted_at IS NULL
+AND published
+AND ($2::uuid IS NULL OR user_id = $2::uuid)
+ORDER BY ts_rank(search_vector, to_tsquery('english', $1)) DESC, created_at DESC
+LIMIT $3
+`
+
+type SearchChirpsParams struct {
+	Query    string
+	AuthorID uuid.NullUUID
+	Limit    int32
+}
+
+func (q *Queries) SearchChirps(ctx context.Context, arg SearchChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirps, arg.Query, arg.AuthorID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.ParentChirpID,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.Kind,
+			&i.ReferencedChirpID,
+			&i.Published,
+			&i.PublishAt,
+			&i.ScrubbedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const softDeleteChirp = `-- name: SoftDeleteChirp :one
+UPDATE chirps
+SET deleted_at = NOW()
+WHERE id = $1
+RETURNING deleted_at
+`
+
+func (q *Queries) SoftDeleteChirp(ctx context.Context, id uuid.UUID) (sql.NullTime, error) {
+	row := q.db.QueryRowContext(ctx, softDeleteChirp, id)
+	var deleted_at sql.NullTime
+	err := row.Scan(&deleted_at)
+	return deleted_at, err
+}
+
+const softDeleteRechirpsOf = `-- name: SoftDeleteRechirpsOf :exec
+UPDATE chirps
+SET deleted_at = $1
+WHERE referenced_chirp_id = $2::uuid
+AND kind = 'rechirp'
+AND deleted_at IS NULL
+`
+
+type SoftDeleteRechirpsOfParams struct {
+	DeletedAt sql.NullTime
+	ChirpID   uuid.UUID
+}
+
+func (q *Queries) SoftDeleteRechirpsOf(ctx context.Context, arg SoftDeleteRechirpsOfParams) error {
+	_, err := q.db.ExecContext(ctx, softDeleteRechirpsOf, arg.DeletedAt, arg.ChirpID)
+	return err
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, search_vector, parent_chirp_id, deleted_at, like_count, kind, referenced_chirp_id, published, publish_at, scrubbed_at
+`
+
+type UpdateChirpBodyParams struct {
+	ID   uuid.UUID
+	Body string
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.ID, arg.Body)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.SearchVector,
+		&i.ParentChirpID,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.Kind,
+		&i.ReferencedChirpID,
+		&i.Published,
+		&i.PublishAt,
+		&i.ScrubbedAt,
+	)
+	return i, err
+}
+
+const userHasRechirped = `-- name: UserHasRechirped :one
+SELECT EXISTS (
+    SELECT 1 FROM chirps
+    WHERE user_id = $1
+    AND referenced_chirp_id = $2::uuid
+    AND kind = 'rechirp'
+    AND deleted_at IS NULL
+)
+`
+
+type UserHasRechirpedParams struct {
+	UserID  uuid.UUID
+	ChirpID uuid.UUID
+}
+
+func (q *Queries) UserHasRechirped(ctx context.Context, arg UserHasRechirpedParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, userHasRechirped, arg.UserID, arg.ChirpID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
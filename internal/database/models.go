@@ -11,21 +11,176 @@ import (
 	"github.com/google/uuid"
 )
 
+type ApiKey struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UserID     uuid.UUID
+	Name       string
+	KeyPrefix  string
+	KeyHash    string
+	Scopes     []string
+	ExpiresAt  time.Time
+	LastUsedAt sql.NullTime
+}
+
+type Bookmark struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
 type Chirp struct {
+	ID                uuid.UUID
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	Body              string
+	UserID            uuid.UUID
+	SearchVector      interface{}
+	ParentChirpID     uuid.NullUUID
+	DeletedAt         sql.NullTime
+	LikeCount         int32
+	Kind              string
+	ReferencedChirpID uuid.NullUUID
+	Published         bool
+	PublishAt         sql.NullTime
+	ScrubbedAt        sql.NullTime
+}
+
+type ChirpAttachment struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	ChirpID     uuid.UUID
+	BlobKey     string
+	ContentType string
+	Width       int32
+	Height      int32
+	AltText     string
+	Position    int32
+}
+
+type ChirpFlag struct {
+	ChirpID   uuid.UUID
+	RuleID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type ChirpHashtag struct {
+	ChirpID   uuid.UUID
+	HashtagID uuid.UUID
+	CreatedAt time.Time
+}
+
+type ChirpRevision struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
+	ChirpID   uuid.UUID
 	Body      string
+}
+
+type ChirpyRedHistory struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UserID      uuid.UUID
+	Event       string
+	IsChirpyRed bool
+	ExpiresAt   sql.NullTime
+	EventAt     sql.NullTime
+}
+
+type ContentRule struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Pattern     string
+	MatchType   string
+	Action      string
+	Replacement string
+}
+
+type Draft struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	UserID        uuid.UUID
+	Body          string
+	ParentChirpID uuid.NullUUID
+}
+
+type Follow struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+}
+
+type Hashtag struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Tag       string
+}
+
+type Like struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
 	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+}
+
+type Mention struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type PolkaEvent struct {
+	ID          string
+	Event       string
+	ProcessedAt time.Time
+}
+
+type Poll struct {
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+	ClosesAt  time.Time
+}
+
+type PollOption struct {
+	ID        uuid.UUID
+	ChirpID   uuid.UUID
+	Position  int32
+	Text      string
+	VoteCount int32
+}
+
+type PollVote struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	OptionID  uuid.UUID
+	CreatedAt time.Time
 }
 
 type RefreshToken struct {
-	Token     string
+	Token      string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	ExpiresAt  time.Time
+	RevokedAt  sql.NullTime
+	FamilyID   uuid.UUID
+	ReplacedBy sql.NullString
+	DeviceName string
+	UserAgent  string
+	IpAddress  string
+	SignedInAt time.Time
+	LastUsedAt time.Time
+}
+
+type SecurityEvent struct {
+	ID        uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
 	UserID    uuid.UUID
-	ExpiresAt time.Time
-	RevokedAt sql.NullTime
+	Event     string
+	Detail    string
 }
 
 type User struct {
@@ -35,4 +190,7 @@ type User struct {
 	Email          string
 	HashedPassword string
 	IsChirpyRed    bool
+	Handle         sql.NullString
+	RedExpiresAt   sql.NullTime
+	TokenVersion   int32
 }
@@ -0,0 +1,76 @@
+package entities
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const maxEntityLength = 64
+
+var handlePattern = regexp.MustCompile(`^[a-z0-9_]{3,30}$`)
+
+// ExtractHashtags returns the distinct hashtags in body, lowercased and
+// without the leading #, in order of first appearance.
+func ExtractHashtags(body string) []string {
+	return extract(body, '#')
+}
+
+// ExtractMentions returns the distinct handles mentioned with @ in body,
+// lowercased and without the leading @, in order of first appearance.
+func ExtractMentions(body string) []string {
+	mentions := []string{}
+	for _, name := range extract(body, '@') {
+		if handlePattern.MatchString(name) {
+			mentions = append(mentions, name)
+		}
+	}
+	return mentions
+}
+
+// NormalizeHandle lowercases a handle and strips any leading @, reporting
+// whether the result is 3 to 30 ASCII letters, digits or underscores.
+func NormalizeHandle(handle string) (string, bool) {
+	handle = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(handle), "@"))
+	return handle, handlePattern.MatchString(handle)
+}
+
+func extract(body string, marker rune) []string {
+	seen := map[string]bool{}
+	found := []string{}
+	runes := []rune(body)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != marker {
+			continue
+		}
+		// skip markers glued to a word, such as the @ in an email address
+		if i > 0 && isEntityRune(runes[i-1]) {
+			continue
+		}
+		j := i + 1
+		for j < len(runes) && isEntityRune(runes[j]) {
+			j++
+		}
+		name := strings.ToLower(string(runes[i+1 : j]))
+		i = j - 1
+		if name == "" || len([]rune(name)) > maxEntityLength || !hasLetter(name) || seen[name] {
+			continue
+		}
+		seen[name] = true
+		found = append(found, name)
+	}
+	return found
+}
+
+func isEntityRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+func hasLetter(s string) bool {
+	for _, r := range s {
+		if unicode.IsLetter(r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package entities
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExtractHashtags(t *testing.T) {
+	cases := []struct {
+		body     string
+		expected []string
+	}{
+		{"no tags here", []string{}},
+		{"#Go is fun #go", []string{"go"}},
+		{"loving #chirpy, #golang!", []string{"chirpy", "golang"}},
+		{"issue#42 and #42 are not tags", []string{}},
+		{"#snake_case #日本語", []string{"snake_case", "日本語"}},
+		{"##double", []string{"double"}},
+	}
+
+	for _, c := range cases {
+		got := ExtractHashtags(c.body)
+		if !slices.Equal(got, c.expected) {
+			t.Fatalf("ExtractHashtags(%q): expected %v, got %v", c.body, c.expected, got)
+		}
+	}
+}
+
+func TestExtractMentions(t *testing.T) {
+	cases := []struct {
+		body     string
+		expected []string
+	}{
+		{"hi @Alice and @bob_99, also @alice", []string{"alice", "bob_99"}},
+		{"mail me at bob@example.com", []string{}},
+		{"too short @ab, non-ascii @jürgen", []string{}},
+	}
+
+	for _, c := range cases {
+		got := ExtractMentions(c.body)
+		if !slices.Equal(got, c.expected) {
+			t.Fatalf("ExtractMentions(%q): expected %v, got %v", c.body, c.expected, got)
+		}
+	}
+}
+
+func TestNormalizeHandle(t *testing.T) {
+	handle, ok := NormalizeHandle(" @Chirpy_Fan ")
+	if !ok || handle != "chirpy_fan" {
+		t.Fatalf("Expected valid handle chirpy_fan, got %q (valid: %v)", handle, ok)
+	}
+
+	for _, invalid := range []string{"", "ab", "has space", "dash-name", "waytoolonghandlethatgoesonandon"} {
+		if _, ok := NormalizeHandle(invalid); ok {
+			t.Fatalf("Expected %q to be an invalid handle", invalid)
+		}
+	}
+}
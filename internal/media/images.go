@@ -0,0 +1,136 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // register decoders for image.DecodeConfig
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+)
+
+var (
+	ErrUnsupportedType = errors.New("image must be a JPEG, PNG or GIF")
+	ErrMalformedImage  = errors.New("image data is malformed")
+)
+
+// Image is an uploaded image that passed validation, with its metadata
+// already removed from Data.
+type Image struct {
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+	Data        []byte
+}
+
+// ProcessImage checks that data is a supported image no larger than
+// maxDimension pixels on either side and strips EXIF, XMP and text metadata
+// without re-encoding the pixels.
+func ProcessImage(data []byte, maxDimension int) (Image, error) {
+	contentType := http.DetectContentType(data)
+	extensions := map[string]string{
+		"image/jpeg": ".jpg",
+		"image/png":  ".png",
+		"image/gif":  ".gif",
+	}
+	ext, ok := extensions[contentType]
+	if !ok {
+		return Image{}, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, ErrMalformedImage
+	}
+	if config.Width > maxDimension || config.Height > maxDimension {
+		return Image{}, fmt.Errorf("image must be at most %dx%d pixels", maxDimension, maxDimension)
+	}
+
+	// GIFs carry no EXIF block, so they are stored as uploaded
+	stripped := data
+	switch contentType {
+	case "image/jpeg":
+		stripped, err = stripJPEG(data)
+	case "image/png":
+		stripped, err = stripPNG(data)
+	}
+	if err != nil {
+		return Image{}, err
+	}
+
+	return Image{
+		ContentType: contentType,
+		Extension:   ext,
+		Width:       config.Width,
+		Height:      config.Height,
+		Data:        stripped,
+	}, nil
+}
+
+// stripJPEG drops APP1 (EXIF, XMP), APP13 (IPTC) and comment segments.
+// Everything from the start-of-scan marker on is image data and is kept.
+func stripJPEG(data []byte) ([]byte, error) {
+	const (
+		markerSOS  = 0xDA
+		markerAPP1 = 0xE1
+		markerAPPD = 0xED
+		markerCOM  = 0xFE
+	)
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+	i := 2
+	for {
+		if i+4 > len(data) || data[i] != 0xFF {
+			return nil, ErrMalformedImage
+		}
+		marker := data[i+1]
+		if marker == markerSOS {
+			out.Write(data[i:])
+			return out.Bytes(), nil
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, ErrMalformedImage
+		}
+		if marker != markerAPP1 && marker != markerAPPD && marker != markerCOM {
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+}
+
+// stripPNG drops the eXIf, text and timestamp chunks.
+func stripPNG(data []byte) ([]byte, error) {
+	dropped := map[string]bool{
+		"eXIf": true,
+		"tEXt": true,
+		"iTXt": true,
+		"zTXt": true,
+		"tIME": true,
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:8])
+	i := 8
+	for i < len(data) {
+		if i+8 > len(data) {
+			return nil, ErrMalformedImage
+		}
+		length := int(binary.BigEndian.Uint32(data[i : i+4]))
+		chunkType := string(data[i+4 : i+8])
+		// length, type, data and CRC
+		end := i + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, ErrMalformedImage
+		}
+		if !dropped[chunkType] {
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+	return out.Bytes(), nil
+}
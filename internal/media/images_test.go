@@ -0,0 +1,97 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func testImage(width, height int) image.Image {
+	return image.NewRGBA(image.Rect(0, 0, width, height))
+}
+
+func pngChunk(chunkType string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, chunkType...)
+	chunk = append(chunk, data...)
+	crc := crc32.ChecksumIEEE(append([]byte(chunkType), data...))
+	return binary.BigEndian.AppendUint32(chunk, crc)
+}
+
+func TestProcessImagePNGStripsMetadata(t *testing.T) {
+	buf := bytes.Buffer{}
+	if err := png.Encode(&buf, testImage(4, 3)); err != nil {
+		t.Fatalf("png.Encode failed: %v", err)
+	}
+	encoded := buf.Bytes()
+
+	// insert text and EXIF chunks right after IHDR (8 + 25 bytes)
+	withMeta := append([]byte{}, encoded[:33]...)
+	withMeta = append(withMeta, pngChunk("tEXt", []byte("Author\x00someone"))...)
+	withMeta = append(withMeta, pngChunk("eXIf", []byte("MM\x00*GPS"))...)
+	withMeta = append(withMeta, encoded[33:]...)
+
+	img, err := ProcessImage(withMeta, 100)
+	if err != nil {
+		t.Fatalf("ProcessImage failed: %v", err)
+	}
+	if img.ContentType != "image/png" || img.Extension != ".png" {
+		t.Fatalf("Unexpected type %q / %q", img.ContentType, img.Extension)
+	}
+	if img.Width != 4 || img.Height != 3 {
+		t.Fatalf("Expected 4x3, got %dx%d", img.Width, img.Height)
+	}
+	if !bytes.Equal(img.Data, encoded) {
+		t.Fatal("Expected metadata chunks to be removed")
+	}
+	if _, err := png.Decode(bytes.NewReader(img.Data)); err != nil {
+		t.Fatalf("Stripped PNG does not decode: %v", err)
+	}
+}
+
+func TestProcessImageJPEGStripsMetadata(t *testing.T) {
+	buf := bytes.Buffer{}
+	if err := jpeg.Encode(&buf, testImage(8, 8), nil); err != nil {
+		t.Fatalf("jpeg.Encode failed: %v", err)
+	}
+	encoded := buf.Bytes()
+
+	exif := []byte("Exif\x00\x00GPS data")
+	segment := []byte{0xFF, 0xE1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(exif)+2))
+	segment = append(segment, exif...)
+	withMeta := append([]byte{}, encoded[:2]...)
+	withMeta = append(withMeta, segment...)
+	withMeta = append(withMeta, encoded[2:]...)
+
+	img, err := ProcessImage(withMeta, 100)
+	if err != nil {
+		t.Fatalf("ProcessImage failed: %v", err)
+	}
+	if bytes.Contains(img.Data, []byte("GPS data")) {
+		t.Fatal("Expected EXIF segment to be removed")
+	}
+	if !bytes.Equal(img.Data, encoded) {
+		t.Fatal("Expected all other segments to be kept")
+	}
+}
+
+func TestProcessImageRejects(t *testing.T) {
+	_, err := ProcessImage([]byte("just some text"), 100)
+	if err != ErrUnsupportedType {
+		t.Fatalf("Expected ErrUnsupportedType, got %v", err)
+	}
+
+	buf := bytes.Buffer{}
+	if err := png.Encode(&buf, testImage(200, 10)); err != nil {
+		t.Fatalf("png.Encode failed: %v", err)
+	}
+	_, err = ProcessImage(buf.Bytes(), 100)
+	if err == nil {
+		t.Fatal("Expected error for oversized dimensions, got nil")
+	}
+}
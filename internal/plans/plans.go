@@ -0,0 +1,58 @@
+package plans
+
+import (
+	"time"
+
+	"github.com/seiobata/chirpy/internal/ratelimit"
+)
+
+// Plan is a membership tier. Everything a tier unlocks is listed in
+// entitlements, so granting a plan grants all of it.
+type Plan string
+
+const (
+	Free Plan = "free"
+	Red  Plan = "chirpy_red"
+)
+
+// Entitlements are the limits and features that come with a plan. Free
+// users had no chirp rate limit before plans existed; they now get 30 chirps
+// an hour so that Red's higher rate means something.
+type Entitlements struct {
+	MaxChirpLength         int
+	CanEditChirps          bool
+	MaxAttachmentsPerChirp int
+	ChirpRate              ratelimit.Rate
+}
+
+var entitlements = map[Plan]Entitlements{
+	Free: {
+		MaxChirpLength:         140,
+		CanEditChirps:          false,
+		MaxAttachmentsPerChirp: 4,
+		ChirpRate:              ratelimit.Rate{Events: 30, Per: time.Hour},
+	},
+	Red: {
+		MaxChirpLength:         500,
+		CanEditChirps:          true,
+		MaxAttachmentsPerChirp: 10,
+		ChirpRate:              ratelimit.Rate{Events: 300, Per: time.Hour},
+	},
+}
+
+// ForUser returns the plan of a user with the given Chirpy Red status.
+func ForUser(isChirpyRed bool) Plan {
+	if isChirpyRed {
+		return Red
+	}
+	return Free
+}
+
+// Entitlements returns what the plan unlocks. Unknown plans get the free
+// tier.
+func (p Plan) Entitlements() Entitlements {
+	if e, ok := entitlements[p]; ok {
+		return e
+	}
+	return entitlements[Free]
+}
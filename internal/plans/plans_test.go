@@ -0,0 +1,35 @@
+package plans
+
+import "testing"
+
+func TestForUser(t *testing.T) {
+	if got := ForUser(true); got != Red {
+		t.Fatalf("Expected Red for a Chirpy Red user, got %q", got)
+	}
+	if got := ForUser(false); got != Free {
+		t.Fatalf("Expected Free for other users, got %q", got)
+	}
+}
+
+func TestRedUnlocksMore(t *testing.T) {
+	free := Free.Entitlements()
+	red := Red.Entitlements()
+	if free.CanEditChirps || !red.CanEditChirps {
+		t.Fatal("Expected only Red to be able to edit chirps")
+	}
+	if red.MaxChirpLength <= free.MaxChirpLength {
+		t.Fatalf("Expected Red chirps to be longer, got %d vs %d", red.MaxChirpLength, free.MaxChirpLength)
+	}
+	if red.MaxAttachmentsPerChirp <= free.MaxAttachmentsPerChirp {
+		t.Fatalf("Expected Red to allow more attachments, got %d vs %d", red.MaxAttachmentsPerChirp, free.MaxAttachmentsPerChirp)
+	}
+	if red.ChirpRate.Events*int(free.ChirpRate.Per) <= free.ChirpRate.Events*int(red.ChirpRate.Per) {
+		t.Fatal("Expected Red to have a higher chirp rate")
+	}
+}
+
+func TestUnknownPlanIsFree(t *testing.T) {
+	if Plan("gold").Entitlements() != Free.Entitlements() {
+		t.Fatal("Expected an unknown plan to get free entitlements")
+	}
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// pruneInterval is how often idle buckets are dropped.
+const pruneInterval = time.Minute * 10
+
+// Rate allows Events events every Per, in bursts of up to Events.
+type Rate struct {
+	Events int
+	Per    time.Duration
+}
+
+// Limiter is an in-memory token bucket limiter keyed by arbitrary strings,
+// such as user IDs. Each call to Allow says which rate applies, so a key's
+// rate can change between calls. Buckets are per process: every instance
+// allows the full rate, and a restart refills them.
+type Limiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time
+}
+
+func New() *Limiter {
+	return &Limiter{
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+// Allow takes one event from key's bucket. When the bucket is empty it
+// reports false and how long until the next event is allowed.
+func (l *Limiter) Allow(key string, rate Rate) (bool, time.Duration) {
+	if rate.Events <= 0 || rate.Per <= 0 {
+		return false, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.prune(now)
+
+	capacity := float64(rate.Events)
+	perToken := rate.Per / time.Duration(rate.Events)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		l.buckets[key] = b
+	}
+	elapsed := now.Sub(b.updated)
+	if elapsed > 0 {
+		b.tokens += float64(elapsed) / float64(perToken)
+		b.updated = now
+	}
+	b.tokens = min(b.tokens, capacity)
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) * float64(perToken))
+		return false, wait
+	}
+	b.tokens--
+	b.full = now.Add(time.Duration((capacity - b.tokens) * float64(perToken)))
+	return true, 0
+}
+
+// prune drops buckets that have refilled, since a fresh bucket behaves the
+// same. The caller must hold l.mu.
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < pruneInterval {
+		return
+	}
+	l.lastPrune = now
+	for key, b := range l.buckets {
+		if !now.Before(b.full) {
+			delete(l.buckets, key)
+		}
+	}
+}
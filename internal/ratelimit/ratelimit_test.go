@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllow(t *testing.T) {
+	l := New()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	rate := Rate{Events: 3, Per: time.Minute}
+
+	for i := range 3 {
+		if ok, _ := l.Allow("a", rate); !ok {
+			t.Fatalf("Expected event %d to be allowed", i+1)
+		}
+	}
+	ok, wait := l.Allow("a", rate)
+	if ok || wait != time.Second*20 {
+		t.Fatalf("Expected a 20s wait after the burst, got allowed: %v, wait: %v", ok, wait)
+	}
+	if ok, _ := l.Allow("b", rate); !ok {
+		t.Fatal("Expected keys to have separate buckets")
+	}
+
+	now = now.Add(time.Second * 20)
+	if ok, _ := l.Allow("a", rate); !ok {
+		t.Fatal("Expected one event to be allowed after refilling a token")
+	}
+	if ok, _ := l.Allow("a", rate); ok {
+		t.Fatal("Expected the bucket to be empty again")
+	}
+}
+
+func TestAllowRateChange(t *testing.T) {
+	l := New()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+
+	if ok, _ := l.Allow("a", Rate{Events: 1, Per: time.Hour}); !ok {
+		t.Fatal("Expected the first event to be allowed")
+	}
+	if ok, _ := l.Allow("a", Rate{Events: 1, Per: time.Hour}); ok {
+		t.Fatal("Expected the second event to be limited")
+	}
+	now = now.Add(time.Minute)
+	if ok, _ := l.Allow("a", Rate{Events: 60, Per: time.Hour}); !ok {
+		t.Fatal("Expected a faster rate to refill the bucket sooner")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	l := New()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+
+	l.Allow("a", Rate{Events: 2, Per: time.Minute})
+	now = now.Add(pruneInterval)
+	l.Allow("b", Rate{Events: 2, Per: time.Minute})
+	if _, ok := l.buckets["a"]; ok {
+		t.Fatal("Expected the refilled bucket to be pruned")
+	}
+	if _, ok := l.buckets["b"]; !ok {
+		t.Fatal("Expected the active bucket to be kept")
+	}
+}
@@ -0,0 +1,59 @@
+package search
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+const maxTerms = 32
+
+var ErrEmptyQuery = errors.New("query has no searchable terms")
+
+// BuildTSQuery turns a user search string into to_tsquery syntax.
+// Bare words are ANDed together, "quoted text" becomes a phrase match
+// and a trailing * on a word makes it a prefix match.
+func BuildTSQuery(q string) (string, error) {
+	clauses := []string{}
+	count := 0
+	for i, segment := range strings.Split(q, `"`) {
+		terms := splitTerms(segment)
+		if count+len(terms) > maxTerms {
+			return "", errors.New("query has too many terms")
+		}
+		count += len(terms)
+		if len(terms) == 0 {
+			continue
+		}
+		// odd segments sit between a pair of quotes
+		if i%2 == 1 && len(terms) > 1 {
+			clauses = append(clauses, "("+strings.Join(terms, " <-> ")+")")
+			continue
+		}
+		clauses = append(clauses, terms...)
+	}
+	if len(clauses) == 0 {
+		return "", ErrEmptyQuery
+	}
+	return strings.Join(clauses, " & "), nil
+}
+
+// splitTerms breaks a segment into lexemes made of letters and digits only,
+// so no user input can reach to_tsquery as an operator.
+func splitTerms(segment string) []string {
+	terms := []string{}
+	for _, field := range strings.Fields(segment) {
+		prefix := strings.HasSuffix(field, "*")
+		words := strings.FieldsFunc(field, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if len(words) == 0 {
+			continue
+		}
+		if prefix {
+			words[len(words)-1] += ":*"
+		}
+		terms = append(terms, words...)
+	}
+	return terms
+}
@@ -0,0 +1,36 @@
+package search
+
+import "testing"
+
+func TestBuildTSQuery(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"hello world", "hello & world"},
+		{`"hello world"`, "(hello <-> world)"},
+		{"chir*", "chir:*"},
+		{`go "big chir*" news`, "go & (big <-> chir:*) & news"},
+		{"it's & | !x", "it & s & x"},
+		{`"single"`, "single"},
+	}
+
+	for _, c := range cases {
+		got, err := BuildTSQuery(c.input)
+		if err != nil {
+			t.Fatalf("BuildTSQuery(%q) failed: %v", c.input, err)
+		}
+		if got != c.expected {
+			t.Fatalf("BuildTSQuery(%q): expected %q, got %q", c.input, c.expected, got)
+		}
+	}
+}
+
+func TestBuildTSQueryEmpty(t *testing.T) {
+	for _, input := range []string{"", "   ", `"" * &`} {
+		_, err := BuildTSQuery(input)
+		if err != ErrEmptyQuery {
+			t.Fatalf("BuildTSQuery(%q): expected ErrEmptyQuery, got %v", input, err)
+		}
+	}
+}
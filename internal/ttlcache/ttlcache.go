@@ -0,0 +1,66 @@
+package ttlcache
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache is a concurrency-safe map whose entries expire ttl after they are
+// set. Expired entries are dropped as new ones are set.
+type Cache[K comparable, V any] struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	entries   map[K]entry[V]
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+type entry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+func New[K comparable, V any](ttl time.Duration) *Cache[K, V] {
+	return &Cache[K, V]{
+		ttl:     ttl,
+		entries: map[K]entry[V]{},
+		now:     time.Now,
+	}
+}
+
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || !c.now().Before(e.expires) {
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.SetIf(key, value, func(V) bool { return true })
+}
+
+// SetIf sets key to value when there is no live entry for it, or when
+// replace reports that value should take the place of the current one.
+// Checking and setting happen under one lock, so a slow writer holding a
+// stale value can't overwrite a newer one.
+func (c *Cache[K, V]) SetIf(key K, value V, replace func(current V) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if e, ok := c.entries[key]; !ok || !now.Before(e.expires) || replace(e.value) {
+		c.entries[key] = entry[V]{value: value, expires: now.Add(c.ttl)}
+	}
+	if now.Sub(c.lastPrune) < c.ttl {
+		return
+	}
+	c.lastPrune = now
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package ttlcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetSet(t *testing.T) {
+	c := New[string, int](time.Minute)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Expected a miss for an unset key")
+	}
+	c.Set("a", 1)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("Expected 1, got %v (found: %v)", v, ok)
+	}
+	c.Set("a", 2)
+	if v, _ := c.Get("a"); v != 2 {
+		t.Fatalf("Expected Set to overwrite, got %v", v)
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Expected the entry to expire after the ttl")
+	}
+}
+
+func TestSetIf(t *testing.T) {
+	c := New[string, int](time.Minute)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	newer := func(value int) func(int) bool {
+		return func(current int) bool { return value > current }
+	}
+
+	c.SetIf("a", 2, newer(2))
+	if v, ok := c.Get("a"); !ok || v != 2 {
+		t.Fatalf("Expected SetIf to fill a missing key, got %v (found: %v)", v, ok)
+	}
+	c.SetIf("a", 1, newer(1))
+	if v, _ := c.Get("a"); v != 2 {
+		t.Fatalf("Expected SetIf to keep the newer value, got %v", v)
+	}
+	c.SetIf("a", 3, newer(3))
+	if v, _ := c.Get("a"); v != 3 {
+		t.Fatalf("Expected SetIf to replace the older value, got %v", v)
+	}
+
+	now = now.Add(time.Minute)
+	c.SetIf("a", 1, newer(1))
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("Expected SetIf to replace an expired entry, got %v (found: %v)", v, ok)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	c := New[string, int](time.Minute)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	c.Set("a", 1)
+	now = now.Add(time.Minute)
+	c.Set("b", 2)
+	if _, ok := c.entries["a"]; ok {
+		t.Fatal("Expected the expired entry to be pruned")
+	}
+	if _, ok := c.entries["b"]; !ok {
+		t.Fatal("Expected the new entry to be kept")
+	}
+}
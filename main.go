@@ -9,19 +9,87 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq" // driver for database/sql package
+	"github.com/seiobata/chirpy/internal/auth"
+	"github.com/seiobata/chirpy/internal/blobstore"
+	"github.com/seiobata/chirpy/internal/contentfilter"
 	"github.com/seiobata/chirpy/internal/database"
+	"github.com/seiobata/chirpy/internal/ratelimit"
+	"github.com/seiobata/chirpy/internal/ttlcache"
 )
 
 const (
-	rootPath       = "."
-	port           = "8080"
-	maxChirpLength = 140
+	rootPath   = "."
+	uploadsDir = "uploads"
+	port       = "8080"
+
+	// pagination
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+
+	// reply threads
+	defaultThreadDepth = 10
+	maxThreadDepth     = 50
+	maxThreadReplies   = 500
+
+	// trending hashtags
+	trendingWindow          = time.Hour * 24
+	trendingHalfLife        = time.Hour * 6
+	trendingRefreshInterval = time.Minute
+	maxTrendingHashtags     = 20
+
+	// image attachments; the per-chirp count depends on the plan
+	maxImageBytes     = 5 << 20
+	maxImageDimension = 4096
+	maxAltTextLength  = 1000
+
+	// scheduled chirps
+	scheduledPublishInterval = time.Second * 15
+	maxScheduleAhead         = time.Hour * 24 * 365
+
+	// polls
+	minPollOptions      = 2
+	maxPollOptions      = 4
+	maxPollOptionLength = 25
+	maxPollDuration     = time.Hour * 24 * 7
+
+	// drafts
+	maxDraftLength = 10000
+
+	// deleted chirps; RESTORE_WINDOW and DELETED_RETENTION override the defaults
+	defaultRestoreWindow    = time.Hour * 24 * 7
+	defaultDeletedRetention = time.Hour * 24 * 30
+	purgeInterval           = time.Hour
+
+	// Polka webhooks
+	polkaSignatureTolerance = time.Minute * 5
+	maxWebhookBytes         = 1 << 20
+
+	// Chirpy Red membership, when Polka doesn't say how long it lasts
+	chirpyRedTerm          = time.Hour * 24 * 30
+	chirpyRedSweepInterval = time.Minute * 5
+
+	// content filter
+	contentRulesRefreshInterval = time.Second * 30
+
+	// sessions
+	maxDeviceNameLength  = 100
+	tokenVersionCacheTTL = time.Second * 15
+
+	// personal API keys
+	maxAPIKeysPerUser   = 10
+	maxAPIKeyNameLength = 100
+	maxAPIKeyLifetime   = time.Hour * 24 * 365
+	apiKeyTouchInterval = time.Minute * 5
 
 	// token expiration
 	accessTkExp  = time.Hour
@@ -31,9 +99,19 @@ const (
 type apiConfig struct {
 	fileserverHits atomic.Int32
 	db             *database.Queries
+	dbConn         *sql.DB
 	platform       string
-	secret         string
-	polkaSecret    string
+	tokenKeys      *auth.KeySet
+	tokenVersions  *ttlcache.Cache[uuid.UUID, int32]
+	polkaSecrets   []string
+	trending       atomic.Pointer[[]TrendingHashtag]
+	blobs          blobstore.BlobStore
+	adminKey       string
+	contentFilter  contentfilter.Reloadable
+	chirpLimiter   *ratelimit.Limiter
+
+	restoreWindow    time.Duration
+	deletedRetention time.Duration
 }
 
 func main() {
@@ -47,14 +125,25 @@ func main() {
 	if platform == "" {
 		log.Fatal("PLATFORM must be set")
 	}
-	secret := os.Getenv("TOKEN_SECRET")
-	if secret == "" {
-		log.Fatal("TOKEN_SECRET must be set")
+	tokenKeys := loadTokenKeys()
+	// POLKA_SECRET takes comma-separated secrets so a new one can be added
+	// before the old one is retired
+	polkaSecrets := []string{}
+	for _, s := range strings.Split(os.Getenv("POLKA_SECRET"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			polkaSecrets = append(polkaSecrets, s)
+		}
 	}
-	polkaSecret := os.Getenv("POLKA_SECRET")
-	if polkaSecret == "" {
+	if len(polkaSecrets) == 0 {
 		log.Fatal("POLKA_SECRET must be set")
 	}
+	// the content rule endpoints stay closed without an admin key
+	adminKey := os.Getenv("ADMIN_API_KEY")
+	restoreWindow := envDuration("RESTORE_WINDOW", defaultRestoreWindow)
+	deletedRetention := envDuration("DELETED_RETENTION", defaultDeletedRetention)
+	if deletedRetention < restoreWindow {
+		log.Fatal("DELETED_RETENTION must not be shorter than RESTORE_WINDOW")
+	}
 
 	// open database connection
 	db, err := sql.Open("postgres", dbURL)
@@ -62,32 +151,94 @@ func main() {
 		log.Fatalf("Failed to open database: %v", err)
 	}
 	dbQueries := database.New(db)
+
+	// uploads live under the file server root and are served from /app
+	blobs, err := blobstore.NewLocalStore(filepath.Join(rootPath, uploadsDir), "/app/"+uploadsDir)
+	if err != nil {
+		log.Fatalf("Failed to open blob store: %v", err)
+	}
+
 	apiCfg := apiConfig{
-		db:          dbQueries,
-		platform:    platform,
-		secret:      secret,
-		polkaSecret: polkaSecret,
+		db:            dbQueries,
+		dbConn:        db,
+		platform:      platform,
+		tokenKeys:     tokenKeys,
+		tokenVersions: ttlcache.New[uuid.UUID, int32](tokenVersionCacheTTL),
+		polkaSecrets:  polkaSecrets,
+		blobs:         blobs,
+		adminKey:      adminKey,
+		chirpLimiter:  ratelimit.New(),
+
+		restoreWindow:    restoreWindow,
+		deletedRetention: deletedRetention,
+	}
+
+	// chirps must not go out unfiltered, so the rules load before serving
+	if err := apiCfg.reloadContentFilter(context.Background()); err != nil {
+		log.Fatalf("Failed to load content rules: %v", err)
 	}
 
 	mux := http.NewServeMux()
-	mux.Handle("/app/", apiCfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(rootPath)))))
+	mux.Handle("/app/", apiCfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(noListingFS{http.Dir(rootPath)}))))
 
 	mux.HandleFunc("POST /api/users", apiCfg.handlerCreateUser)
 	mux.HandleFunc("PUT /api/users", apiCfg.handlerUpdateUser)
+	mux.HandleFunc("GET /api/users/me/mentions", apiCfg.handlerGetMyMentions)
+	mux.HandleFunc("GET /api/users/me/bookmarks", apiCfg.handlerGetMyBookmarks)
+	mux.HandleFunc("POST /api/users/{userID}/follow", apiCfg.handlerFollowUser)
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", apiCfg.handlerUnfollowUser)
 	mux.HandleFunc("POST /api/login", apiCfg.handlerUserLogin)
 	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerUpgradeUserToRed)
 
+	mux.HandleFunc("GET /api/sessions", apiCfg.handlerGetSessions)
+	mux.HandleFunc("DELETE /api/sessions/{sessionID}", apiCfg.handlerRevokeSession)
+	mux.HandleFunc("POST /api/sessions/revoke-all", apiCfg.handlerRevokeAllSessions)
+	mux.HandleFunc("GET /api/keys", apiCfg.handlerGetAPIKeys)
+	mux.HandleFunc("POST /api/keys", apiCfg.handlerCreateAPIKey)
+	mux.HandleFunc("DELETE /api/keys/{keyID}", apiCfg.handlerDeleteAPIKey)
 	mux.HandleFunc("POST /api/refresh", apiCfg.handlerRefreshAccessToken)
 	mux.HandleFunc("POST /api/revoke", apiCfg.handlerRevokeRefreshToken)
 
+	mux.HandleFunc("GET /api/timeline", apiCfg.handlerGetTimeline)
+
 	mux.HandleFunc("POST /api/chirps", apiCfg.handlerCreateChirp)
 	mux.HandleFunc("GET /api/chirps", apiCfg.handlerGetChirps)
+	mux.HandleFunc("GET /api/chirps/search", apiCfg.handlerSearchChirps)
+	mux.HandleFunc("GET /api/chirps/scheduled", apiCfg.handlerGetScheduledChirps)
 	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handlerGetAChirp)
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", apiCfg.handlerUpdateAChirp)
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerDeleteAChirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/restore", apiCfg.handlerRestoreAChirp)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/revisions", apiCfg.handlerGetChirpRevisions)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/thread", apiCfg.handlerGetChirpThread)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/attachments", apiCfg.handlerUploadAttachment)
+	mux.HandleFunc("PUT /api/chirps/{chirpID}/schedule", apiCfg.handlerRescheduleChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/schedule", apiCfg.handlerCancelScheduledChirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/likes", apiCfg.handlerLikeChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/likes", apiCfg.handlerUnlikeChirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/poll/vote", apiCfg.handlerVotePoll)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/bookmark", apiCfg.handlerBookmarkChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/bookmark", apiCfg.handlerUnbookmarkChirp)
+
+	mux.HandleFunc("POST /api/drafts", apiCfg.handlerCreateDraft)
+	mux.HandleFunc("GET /api/drafts", apiCfg.handlerGetDrafts)
+	mux.HandleFunc("PUT /api/drafts/{draftID}", apiCfg.handlerUpdateDraft)
+	mux.HandleFunc("DELETE /api/drafts/{draftID}", apiCfg.handlerDeleteDraft)
+	mux.HandleFunc("POST /api/drafts/{draftID}/publish", apiCfg.handlerPublishDraft)
+
+	mux.HandleFunc("GET /api/hashtags/{tag}/chirps", apiCfg.handlerGetHashtagChirps)
+	mux.HandleFunc("GET /api/trending", apiCfg.handlerGetTrending)
+
+	mux.HandleFunc("GET /.well-known/jwks.json", apiCfg.handlerJWKS)
 
 	mux.HandleFunc("GET /api/healthz", handlerReadiness)
 	mux.HandleFunc("GET /admin/metrics", apiCfg.handlerHitsMetrics)
 	mux.HandleFunc("POST /admin/reset", apiCfg.handlerReset)
+	mux.HandleFunc("GET /admin/content-rules", apiCfg.handlerGetContentRules)
+	mux.HandleFunc("POST /admin/content-rules", apiCfg.handlerCreateContentRule)
+	mux.HandleFunc("PUT /admin/content-rules/{ruleID}", apiCfg.handlerUpdateContentRule)
+	mux.HandleFunc("DELETE /admin/content-rules/{ruleID}", apiCfg.handlerDeleteContentRule)
+	mux.HandleFunc("GET /admin/content-flags", apiCfg.handlerGetContentFlags)
 
 	server := http.Server{
 		Handler: mux,
@@ -102,6 +253,23 @@ func main() {
 		}
 	}()
 
+	// start background workers; they stop when workerCtx is cancelled
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var workers sync.WaitGroup
+	startWorker := func(run func(context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(workerCtx)
+		}()
+	}
+	startWorker(apiCfg.runTrendingRefresher)
+	startWorker(apiCfg.runScheduledPublisher)
+	startWorker(apiCfg.runDeletedChirpPurger)
+	startWorker(apiCfg.runContentFilterRefresher)
+	startWorker(apiCfg.runChirpyRedExpirySweeper)
+
 	// channel for shutdown
 	quit := make(chan bool, 1)
 
@@ -135,5 +303,66 @@ func main() {
 	if err := server.Shutdown(ctx); err != nil {
 		log.Fatalf("Server failed to properly shutdown: %v", err)
 	}
+	stopWorkers()
+	workers.Wait()
 	log.Println("Server closed")
 }
+
+// envDuration reads an optional duration such as "72h" from the environment.
+func envDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Fatalf("%s must be a positive duration", key)
+	}
+	return d
+}
+
+// loadTokenKeys builds the access token keys. PEM keys in JWT_KEYS_DIR, named
+// <kid>.pem, take over from TOKEN_SECRET: the one named by JWT_SIGNING_KEY_ID
+// signs, the rest only verify, and TOKEN_SECRET, if still set, only verifies
+// tokens issued before the switch. Without a key directory TOKEN_SECRET signs
+// with HS256.
+func loadTokenKeys() *auth.KeySet {
+	secret := os.Getenv("TOKEN_SECRET")
+	keysDir := os.Getenv("JWT_KEYS_DIR")
+	if keysDir == "" {
+		if secret == "" {
+			log.Fatal("TOKEN_SECRET or JWT_KEYS_DIR must be set")
+		}
+		keys, err := auth.NewKeySet(auth.NewHMACKey("", []byte(secret)))
+		if err != nil {
+			log.Fatalf("Failed to load token keys: %v", err)
+		}
+		return keys
+	}
+
+	keys, err := auth.LoadKeyDir(keysDir)
+	if err != nil {
+		log.Fatalf("Failed to load token keys: %v", err)
+	}
+	signingID := os.Getenv("JWT_SIGNING_KEY_ID")
+	var signing *auth.Key
+	others := []*auth.Key{}
+	for _, key := range keys {
+		if key.ID == signingID {
+			signing = key
+		} else {
+			others = append(others, key)
+		}
+	}
+	if signing == nil {
+		log.Fatalf("JWT_SIGNING_KEY_ID must name a key in %s", keysDir)
+	}
+	if secret != "" {
+		others = append(others, auth.NewHMACKey("", []byte(secret)))
+	}
+	keySet, err := auth.NewKeySet(signing, others...)
+	if err != nil {
+		log.Fatalf("Failed to load token keys: %v", err)
+	}
+	return keySet
+}
@@ -0,0 +1,39 @@
+-- name: CreateAPIKey :one
+INSERT INTO api_keys (id, created_at, user_id, name, key_prefix, key_hash, scopes, expires_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+RETURNING *;
+
+-- name: GetAPIKeys :many
+SELECT * FROM api_keys
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: CountAPIKeys :one
+SELECT COUNT(*) FROM api_keys
+WHERE user_id = $1 AND expires_at > NOW();
+
+-- name: GetAPIKeyByHash :one
+SELECT * FROM api_keys
+WHERE key_hash = $1 AND expires_at > NOW();
+
+-- name: TouchAPIKey :exec
+UPDATE api_keys
+SET last_used_at = NOW()
+WHERE id = sqlc.arg('id') AND (last_used_at IS NULL OR last_used_at < sqlc.arg('stale_before')::timestamp);
+
+-- name: DeleteAPIKey :execrows
+DELETE FROM api_keys
+WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteAllAPIKeys :execrows
+DELETE FROM api_keys
+WHERE user_id = $1;
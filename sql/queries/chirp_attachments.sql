@@ -0,0 +1,28 @@
+-- name: CreateChirpAttachment :one
+INSERT INTO chirp_attachments (id, created_at, chirp_id, blob_key, content_type, width, height, alt_text, position)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8
+)
+RETURNING *;
+
+-- name: CountChirpAttachments :one
+SELECT COUNT(*) FROM chirp_attachments
+WHERE chirp_id = $1;
+
+-- name: GetChirpAttachments :many
+SELECT * FROM chirp_attachments
+WHERE chirp_id = $1
+ORDER BY position ASC;
+
+-- name: GetAttachmentsForChirps :many
+SELECT * FROM chirp_attachments
+WHERE chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+ORDER BY chirp_id, position ASC;
@@ -1,22 +1,211 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, parent_chirp_id, kind, referenced_chirp_id, published, publish_at)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
 )
 RETURNING *;
 
--- name: GetChirps :many
+-- name: GetChirpsPageAsc :many
 SELECT * FROM chirps
-ORDER BY created_at ASC;
+WHERE deleted_at IS NULL
+AND published
+AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+AND (sqlc.narg('after_created_at')::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg('after_created_at')::timestamp, sqlc.narg('after_id')::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('limit');
+
+-- name: GetChirpsPageDesc :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+AND published
+AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+AND (sqlc.narg('after_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('after_created_at')::timestamp, sqlc.narg('after_id')::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
 
 -- name: GetAChirp :one
 SELECT * FROM chirps
-WHERE id = $1;
+WHERE id = $1
+AND deleted_at IS NULL
+AND published;
+
+-- name: GetThreadRoot :one
+SELECT * FROM chirps
+WHERE id = $1
+AND published;
 
 -- name: DeleteAChirp :exec
 DELETE FROM chirps
 WHERE id = $1;
+
+-- name: SearchChirps :many
+SELECT * FROM chirps
+WHERE search_vector @@ to_tsquery('english', sqlc.arg('query'))
+AND deleted_at IS NULL
+AND published
+AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+ORDER BY ts_rank(search_vector, to_tsquery('english', sqlc.arg('query'))) DESC, created_at DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetAChirpForUpdate :one
+SELECT * FROM chirps
+WHERE id = $1
+AND deleted_at IS NULL
+FOR UPDATE;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: SoftDeleteChirp :one
+UPDATE chirps
+SET deleted_at = NOW()
+WHERE id = $1
+RETURNING deleted_at;
+
+-- name: GetDeletedChirpForUpdate :one
+SELECT * FROM chirps
+WHERE id = $1
+AND deleted_at IS NOT NULL
+AND scrubbed_at IS NULL
+FOR UPDATE;
+
+-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL
+WHERE id = $1
+RETURNING *;
+
+-- name: PurgeDeletedChirps :many
+WITH purged AS (
+    DELETE FROM chirps
+    WHERE deleted_at < sqlc.arg('deleted_before')::timestamp
+    AND NOT EXISTS (
+        SELECT 1 FROM chirps replies
+        WHERE replies.parent_chirp_id = chirps.id
+    )
+    RETURNING id
+)
+SELECT chirp_attachments.* FROM chirp_attachments
+JOIN purged ON chirp_attachments.chirp_id = purged.id;
+
+-- name: ScrubDeletedChirps :many
+WITH scrubbed AS (
+    UPDATE chirps
+    SET body = '', scrubbed_at = NOW()
+    WHERE deleted_at < sqlc.arg('deleted_before')::timestamp
+    AND scrubbed_at IS NULL
+    RETURNING id
+), removed_revisions AS (
+    DELETE FROM chirp_revisions
+    USING scrubbed
+    WHERE chirp_revisions.chirp_id = scrubbed.id
+)
+DELETE FROM chirp_attachments
+USING scrubbed
+WHERE chirp_attachments.chirp_id = scrubbed.id
+RETURNING chirp_attachments.*;
+
+-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors AS (
+    SELECT c.id, c.parent_chirp_id, 1 AS depth FROM chirps c
+    WHERE c.id = (SELECT p.parent_chirp_id FROM chirps p WHERE p.id = sqlc.arg('chirp_id')::uuid)
+    UNION ALL
+    SELECT c.id, c.parent_chirp_id, a.depth + 1 FROM chirps c
+    JOIN ancestors a ON c.id = a.parent_chirp_id
+    WHERE a.depth < sqlc.arg('max_depth')::int
+)
+SELECT chirps.* FROM chirps
+JOIN ancestors ON chirps.id = ancestors.id
+ORDER BY ancestors.depth DESC;
+
+-- name: GetChirpDescendants :many
+WITH RECURSIVE descendants AS (
+    SELECT c.id, 1 AS depth FROM chirps c
+    WHERE c.parent_chirp_id = sqlc.arg('chirp_id')::uuid
+    AND c.published
+    UNION ALL
+    SELECT c.id, d.depth + 1 FROM chirps c
+    JOIN descendants d ON c.parent_chirp_id = d.id
+    WHERE d.depth < sqlc.arg('max_depth')::int
+    AND c.published
+)
+SELECT chirps.* FROM chirps
+JOIN descendants ON chirps.id = descendants.id
+ORDER BY chirps.created_at ASC
+LIMIT sqlc.arg('limit');
+
+-- name: GetChirpsByIDs :many
+SELECT * FROM chirps
+WHERE id = ANY(sqlc.arg('ids')::uuid[]);
+
+-- name: UserHasRechirped :one
+SELECT EXISTS (
+    SELECT 1 FROM chirps
+    WHERE user_id = sqlc.arg('user_id')
+    AND referenced_chirp_id = sqlc.arg('chirp_id')::uuid
+    AND kind = 'rechirp'
+    AND deleted_at IS NULL
+);
+
+-- name: SoftDeleteRechirpsOf :exec
+UPDATE chirps
+SET deleted_at = sqlc.arg('deleted_at')
+WHERE referenced_chirp_id = sqlc.arg('chirp_id')::uuid
+AND kind = 'rechirp'
+AND deleted_at IS NULL;
+
+-- name: RestoreRechirpsOf :exec
+UPDATE chirps
+SET deleted_at = NULL
+WHERE referenced_chirp_id = sqlc.arg('chirp_id')::uuid
+AND kind = 'rechirp'
+AND deleted_at = sqlc.arg('deleted_at');
+
+-- name: GetScheduledChirps :many
+SELECT * FROM chirps
+WHERE user_id = $1
+AND NOT published
+AND deleted_at IS NULL
+ORDER BY publish_at ASC;
+
+-- name: RescheduleChirp :one
+UPDATE chirps
+SET publish_at = $2, updated_at = NOW()
+WHERE id = $1
+AND NOT published
+RETURNING *;
+
+-- name: PublishDueChirps :many
+WITH published_chirps AS (
+    UPDATE chirps
+    SET published = true, created_at = NOW(), updated_at = NOW()
+    WHERE NOT published
+    AND publish_at <= NOW()
+    AND deleted_at IS NULL
+    RETURNING id, created_at
+), moved_hashtags AS (
+    UPDATE chirp_hashtags
+    SET created_at = published_chirps.created_at
+    FROM published_chirps
+    WHERE chirp_hashtags.chirp_id = published_chirps.id
+), moved_mentions AS (
+    UPDATE mentions
+    SET created_at = published_chirps.created_at
+    FROM published_chirps
+    WHERE mentions.chirp_id = published_chirps.id
+)
+SELECT id FROM published_chirps;
@@ -0,0 +1,15 @@
+-- name: RecordChirpyRedChange :exec
+INSERT INTO chirpy_red_history (id, created_at, user_id, event, is_chirpy_red, expires_at, event_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+);
+
+-- name: GetLatestChirpyRedEventAt :one
+SELECT MAX(event_at)::timestamp AS latest FROM chirpy_red_history
+WHERE user_id = $1;
@@ -0,0 +1,45 @@
+-- name: CreateContentRule :one
+INSERT INTO content_rules (id, created_at, updated_at, pattern, match_type, action, replacement)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: GetContentRules :many
+SELECT * FROM content_rules
+ORDER BY created_at ASC, id ASC;
+
+-- name: UpdateContentRule :one
+UPDATE content_rules
+SET pattern = $2, match_type = $3, action = $4, replacement = $5, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteContentRule :execrows
+DELETE FROM content_rules
+WHERE id = $1;
+
+-- name: AddChirpFlags :exec
+INSERT INTO chirp_flags (chirp_id, rule_id, created_at)
+SELECT sqlc.arg('chirp_id')::uuid, content_rules.id, NOW()
+FROM content_rules
+WHERE content_rules.id = ANY(sqlc.arg('rule_ids')::uuid[])
+ON CONFLICT (chirp_id, rule_id) DO NOTHING;
+
+-- name: DeleteChirpFlags :exec
+DELETE FROM chirp_flags
+WHERE chirp_id = $1;
+
+-- name: GetChirpFlags :many
+SELECT chirp_flags.chirp_id, chirp_flags.rule_id, chirp_flags.created_at, content_rules.pattern FROM chirp_flags
+JOIN content_rules ON content_rules.id = chirp_flags.rule_id
+JOIN chirps ON chirps.id = chirp_flags.chirp_id
+WHERE chirps.deleted_at IS NULL
+ORDER BY chirp_flags.created_at DESC
+LIMIT $1;
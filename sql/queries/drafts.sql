@@ -0,0 +1,34 @@
+-- name: CreateDraft :one
+INSERT INTO drafts (id, created_at, updated_at, user_id, body, parent_chirp_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: GetDrafts :many
+SELECT * FROM drafts
+WHERE user_id = $1
+ORDER BY updated_at DESC;
+
+-- name: GetDraftForUpdate :one
+SELECT * FROM drafts
+WHERE id = $1
+AND user_id = $2
+FOR UPDATE;
+
+-- name: UpdateDraft :one
+UPDATE drafts
+SET body = $3, parent_chirp_id = $4, updated_at = NOW()
+WHERE id = $1
+AND user_id = $2
+RETURNING *;
+
+-- name: DeleteDraft :execrows
+DELETE FROM drafts
+WHERE id = $1
+AND user_id = $2;
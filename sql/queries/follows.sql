@@ -0,0 +1,30 @@
+-- name: FollowUser :exec
+INSERT INTO follows (id, created_at, follower_id, followee_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2
+)
+ON CONFLICT (follower_id, followee_id) DO NOTHING;
+
+-- name: UnfollowUser :exec
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: GetTimelinePage :many
+SELECT chirps.* FROM follows
+CROSS JOIN LATERAL (
+    SELECT c.id FROM chirps c
+    WHERE c.user_id = follows.followee_id
+    AND c.deleted_at IS NULL
+    AND c.published
+    AND (sqlc.narg('after_created_at')::timestamp IS NULL
+        OR (c.created_at, c.id) < (sqlc.narg('after_created_at')::timestamp, sqlc.narg('after_id')::uuid))
+    ORDER BY c.created_at DESC, c.id DESC
+    LIMIT sqlc.arg('limit')
+) latest
+JOIN chirps ON chirps.id = latest.id
+WHERE follows.follower_id = sqlc.arg('follower_id')
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('limit');
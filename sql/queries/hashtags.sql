@@ -0,0 +1,49 @@
+-- name: UpsertHashtag :one
+INSERT INTO hashtags (id, created_at, tag)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1
+)
+ON CONFLICT (tag) DO UPDATE SET tag = EXCLUDED.tag
+RETURNING *;
+
+-- name: AddChirpHashtag :exec
+INSERT INTO chirp_hashtags (chirp_id, hashtag_id, created_at)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT (chirp_id, hashtag_id) DO NOTHING;
+
+-- name: DeleteChirpHashtags :exec
+DELETE FROM chirp_hashtags
+WHERE chirp_id = $1;
+
+-- name: GetHashtagChirpsPage :many
+SELECT chirps.* FROM chirp_hashtags
+JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+JOIN chirps ON chirps.id = chirp_hashtags.chirp_id
+WHERE hashtags.tag = sqlc.arg('tag')
+AND chirps.deleted_at IS NULL
+AND chirps.published
+AND (sqlc.narg('after_created_at')::timestamp IS NULL
+    OR (chirp_hashtags.created_at, chirp_hashtags.chirp_id) < (sqlc.narg('after_created_at')::timestamp, sqlc.narg('after_id')::uuid))
+ORDER BY chirp_hashtags.created_at DESC, chirp_hashtags.chirp_id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetTrendingHashtags :many
+SELECT
+    hashtags.tag,
+    SUM(POWER(0.5, EXTRACT(EPOCH FROM (NOW() - chirp_hashtags.created_at)) / sqlc.arg('half_life_seconds')::float8))::float8 AS score,
+    COUNT(*) AS uses
+FROM chirp_hashtags
+JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+JOIN chirps ON chirps.id = chirp_hashtags.chirp_id
+WHERE chirp_hashtags.created_at > NOW() - make_interval(secs => sqlc.arg('window_seconds')::float8)
+AND chirps.deleted_at IS NULL
+AND chirps.published
+GROUP BY hashtags.tag
+ORDER BY score DESC
+LIMIT sqlc.arg('limit');
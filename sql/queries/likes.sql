@@ -0,0 +1,32 @@
+-- name: LikeChirp :execrows
+WITH inserted AS (
+    INSERT INTO likes (id, created_at, user_id, chirp_id)
+    VALUES (
+        gen_random_uuid(),
+        NOW(),
+        $1,
+        $2
+    )
+    ON CONFLICT (user_id, chirp_id) DO NOTHING
+    RETURNING chirp_id
+)
+UPDATE chirps
+SET like_count = like_count + 1
+FROM inserted
+WHERE chirps.id = inserted.chirp_id;
+
+-- name: UnlikeChirp :execrows
+WITH deleted AS (
+    DELETE FROM likes
+    WHERE user_id = $1 AND chirp_id = $2
+    RETURNING chirp_id
+)
+UPDATE chirps
+SET like_count = like_count - 1
+FROM deleted
+WHERE chirps.id = deleted.chirp_id;
+
+-- name: GetLikedChirpIDs :many
+SELECT chirp_id FROM likes
+WHERE user_id = $1
+AND chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[]);
@@ -0,0 +1,21 @@
+-- name: AddMentions :exec
+INSERT INTO mentions (chirp_id, user_id, created_at)
+SELECT sqlc.arg('chirp_id')::uuid, users.id, sqlc.arg('created_at')::timestamp
+FROM users
+WHERE users.handle = ANY(sqlc.arg('handles')::text[])
+ON CONFLICT (chirp_id, user_id) DO NOTHING;
+
+-- name: DeleteChirpMentions :exec
+DELETE FROM mentions
+WHERE chirp_id = $1;
+
+-- name: GetMentionsPage :many
+SELECT chirps.* FROM mentions
+JOIN chirps ON chirps.id = mentions.chirp_id
+WHERE mentions.user_id = sqlc.arg('user_id')
+AND chirps.deleted_at IS NULL
+AND chirps.published
+AND (sqlc.narg('after_created_at')::timestamp IS NULL
+    OR (mentions.created_at, mentions.chirp_id) < (sqlc.narg('after_created_at')::timestamp, sqlc.narg('after_id')::uuid))
+ORDER BY mentions.created_at DESC, mentions.chirp_id DESC
+LIMIT sqlc.arg('limit');
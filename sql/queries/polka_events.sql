@@ -0,0 +1,8 @@
+-- name: MarkPolkaEventProcessed :execrows
+INSERT INTO polka_events (id, event, processed_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,55 @@
+-- name: CreatePoll :exec
+INSERT INTO polls (chirp_id, created_at, closes_at)
+VALUES (
+    $1,
+    NOW(),
+    $2
+);
+
+-- name: AddPollOption :exec
+INSERT INTO poll_options (id, chirp_id, position, text)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3
+);
+
+-- name: GetPoll :one
+SELECT * FROM polls
+WHERE chirp_id = $1;
+
+-- name: GetPollOption :one
+SELECT * FROM poll_options
+WHERE id = $1 AND chirp_id = $2;
+
+-- name: GetPollsForChirps :many
+SELECT * FROM polls
+WHERE chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[]);
+
+-- name: GetPollOptionsForChirps :many
+SELECT * FROM poll_options
+WHERE chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+ORDER BY chirp_id, position ASC;
+
+-- name: GetPollVotesByUser :many
+SELECT chirp_id, option_id FROM poll_votes
+WHERE user_id = $1
+AND chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[]);
+
+-- name: CastPollVote :execrows
+WITH inserted AS (
+    INSERT INTO poll_votes (chirp_id, user_id, option_id, created_at)
+    VALUES (
+        $1,
+        $2,
+        $3,
+        NOW()
+    )
+    ON CONFLICT (chirp_id, user_id) DO NOTHING
+    RETURNING option_id
+)
+UPDATE poll_options
+SET vote_count = vote_count + 1
+FROM inserted
+WHERE poll_options.id = inserted.option_id;
@@ -0,0 +1,9 @@
+-- name: RecordSecurityEvent :exec
+INSERT INTO security_events (id, created_at, user_id, event, detail)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3
+);
@@ -1,11 +1,12 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 )
 RETURNING *;
 
@@ -18,11 +19,48 @@ WHERE email = $1;
 
 -- name: UpdateUser :one
 UPDATE users
-SET email = $2, hashed_password = $3, updated_at = NOW()
+SET email = $2, hashed_password = $3, handle = COALESCE(sqlc.narg('handle'), handle), updated_at = NOW()
 WHERE id = $1
 RETURNING *;
 
--- name: UserIsChirpyRed :exec
+-- name: GetUserForUpdate :one
+SELECT * FROM users
+WHERE id = $1
+FOR UPDATE;
+
+-- name: GetTokenVersion :one
+SELECT token_version FROM users
+WHERE id = $1;
+
+-- name: BumpTokenVersion :one
+UPDATE users
+SET token_version = token_version + 1, updated_at = NOW()
+WHERE id = $1
+RETURNING token_version;
+
+-- name: SetChirpyRed :one
 UPDATE users
-SET is_chirpy_red = true, updated_at = NOW()
+SET is_chirpy_red = $2, red_expires_at = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: ExpireChirpyRed :many
+WITH expired AS (
+    UPDATE users
+    SET is_chirpy_red = false, red_expires_at = NULL, updated_at = NOW()
+    WHERE is_chirpy_red
+    AND red_expires_at <= sqlc.arg('expired_before')::timestamp
+    RETURNING id
+)
+INSERT INTO chirpy_red_history (id, created_at, user_id, event, is_chirpy_red, expires_at)
+SELECT gen_random_uuid(), NOW(), expired.id, 'expiry_sweep', false, NULL
+FROM expired
+RETURNING user_id;
+
+-- name: GetUserByID :one
+SELECT * FROM users
 WHERE id = $1;
+
+-- name: GetUserByHandle :one
+SELECT * FROM users
+WHERE handle = $1;
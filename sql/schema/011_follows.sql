@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE follows (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    follower_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    followee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE (follower_id, followee_id),
+    CHECK (follower_id <> followee_id)
+);
+CREATE INDEX follows_followee_id_idx ON follows (followee_id);
+
+-- +goose Down
+DROP TABLE follows;
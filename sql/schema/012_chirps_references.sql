@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN kind TEXT NOT NULL DEFAULT 'original'
+    CHECK (kind IN ('original', 'rechirp', 'quote')),
+ADD COLUMN referenced_chirp_id UUID REFERENCES chirps(id) ON DELETE SET NULL;
+CREATE INDEX chirps_referenced_chirp_id_idx ON chirps (referenced_chirp_id);
+CREATE UNIQUE INDEX chirps_one_rechirp_per_user_idx ON chirps (user_id, referenced_chirp_id)
+WHERE kind = 'rechirp';
+
+-- +goose Down
+DROP INDEX chirps_one_rechirp_per_user_idx;
+DROP INDEX chirps_referenced_chirp_id_idx;
+ALTER TABLE chirps
+DROP COLUMN referenced_chirp_id,
+DROP COLUMN kind;
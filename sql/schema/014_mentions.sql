@@ -0,0 +1,16 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN handle TEXT UNIQUE;
+
+CREATE TABLE mentions (
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (chirp_id, user_id)
+);
+CREATE INDEX mentions_user_id_created_at_idx ON mentions (user_id, created_at, chirp_id);
+
+-- +goose Down
+DROP TABLE mentions;
+ALTER TABLE users
+DROP COLUMN handle;
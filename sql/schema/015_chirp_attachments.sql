@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE chirp_attachments (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    blob_key TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    alt_text TEXT NOT NULL DEFAULT '',
+    position INTEGER NOT NULL,
+    UNIQUE (chirp_id, position)
+);
+
+-- +goose Down
+DROP TABLE chirp_attachments;
@@ -0,0 +1,12 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN published BOOLEAN NOT NULL DEFAULT true,
+ADD COLUMN publish_at TIMESTAMP;
+CREATE INDEX chirps_pending_publish_at_idx ON chirps (publish_at)
+WHERE NOT published;
+
+-- +goose Down
+DROP INDEX chirps_pending_publish_at_idx;
+ALTER TABLE chirps
+DROP COLUMN publish_at,
+DROP COLUMN published;
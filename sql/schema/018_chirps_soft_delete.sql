@@ -0,0 +1,12 @@
+-- +goose Up
+DROP INDEX chirps_one_rechirp_per_user_idx;
+CREATE UNIQUE INDEX chirps_one_rechirp_per_user_idx ON chirps (user_id, referenced_chirp_id)
+WHERE kind = 'rechirp' AND deleted_at IS NULL;
+CREATE INDEX chirps_deleted_at_idx ON chirps (deleted_at)
+WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX chirps_deleted_at_idx;
+DROP INDEX chirps_one_rechirp_per_user_idx;
+CREATE UNIQUE INDEX chirps_one_rechirp_per_user_idx ON chirps (user_id, referenced_chirp_id)
+WHERE kind = 'rechirp';
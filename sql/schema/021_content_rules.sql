@@ -0,0 +1,29 @@
+-- +goose Up
+CREATE TABLE content_rules (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    pattern TEXT NOT NULL,
+    match_type TEXT NOT NULL CHECK (match_type IN ('word', 'substring', 'regex')),
+    action TEXT NOT NULL CHECK (action IN ('replace', 'reject', 'flag')),
+    replacement TEXT NOT NULL DEFAULT ''
+);
+
+-- the words helperValidateBody used to hard-code
+INSERT INTO content_rules (id, created_at, updated_at, pattern, match_type, action)
+VALUES
+    (gen_random_uuid(), NOW(), NOW(), 'kerfuffle', 'word', 'replace'),
+    (gen_random_uuid(), NOW(), NOW(), 'sharbert', 'word', 'replace'),
+    (gen_random_uuid(), NOW(), NOW(), 'fornax', 'word', 'replace');
+
+CREATE TABLE chirp_flags (
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    rule_id UUID NOT NULL REFERENCES content_rules(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (chirp_id, rule_id)
+);
+CREATE INDEX chirp_flags_created_at_idx ON chirp_flags (created_at);
+
+-- +goose Down
+DROP TABLE chirp_flags;
+DROP TABLE content_rules;
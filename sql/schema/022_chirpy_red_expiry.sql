@@ -0,0 +1,21 @@
+-- +goose Up
+-- members from before expiry was tracked keep a NULL expiry and stay Red
+-- until Polka downgrades them
+ALTER TABLE users
+ADD COLUMN red_expires_at TIMESTAMP;
+CREATE INDEX users_red_expires_at_idx ON users (red_expires_at) WHERE is_chirpy_red;
+
+CREATE TABLE chirpy_red_history (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    event TEXT NOT NULL,
+    is_chirpy_red BOOLEAN NOT NULL,
+    expires_at TIMESTAMP
+);
+CREATE INDEX chirpy_red_history_user_id_idx ON chirpy_red_history (user_id, created_at);
+
+-- +goose Down
+DROP TABLE chirpy_red_history;
+ALTER TABLE users
+DROP red_expires_at;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- webhook events already handled, so redeliveries change nothing
+CREATE TABLE polka_events (
+    id TEXT PRIMARY KEY,
+    event TEXT NOT NULL,
+    processed_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE polka_events;
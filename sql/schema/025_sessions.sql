@@ -0,0 +1,29 @@
+-- +goose Up
+-- a session is a refresh token family; each token in it carries the
+-- session's details forward
+ALTER TABLE refresh_tokens
+ADD COLUMN device_name TEXT NOT NULL DEFAULT '',
+ADD COLUMN user_agent TEXT NOT NULL DEFAULT '',
+ADD COLUMN ip_address TEXT NOT NULL DEFAULT '',
+ADD COLUMN signed_in_at TIMESTAMP,
+ADD COLUMN last_used_at TIMESTAMP;
+UPDATE refresh_tokens SET signed_in_at = created_at, last_used_at = updated_at;
+ALTER TABLE refresh_tokens
+ALTER COLUMN signed_in_at SET NOT NULL,
+ALTER COLUMN last_used_at SET NOT NULL;
+CREATE INDEX refresh_tokens_user_id_idx ON refresh_tokens (user_id) WHERE revoked_at IS NULL;
+
+-- access tokens issued before this time are no longer accepted
+ALTER TABLE users
+ADD COLUMN tokens_valid_after TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE users
+DROP tokens_valid_after;
+DROP INDEX refresh_tokens_user_id_idx;
+ALTER TABLE refresh_tokens
+DROP device_name,
+DROP user_agent,
+DROP ip_address,
+DROP signed_in_at,
+DROP last_used_at;
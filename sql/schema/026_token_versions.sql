@@ -0,0 +1,17 @@
+-- +goose Up
+-- access tokens carry the version they were issued at; bumping it retires
+-- them all, which replaces the revocation timestamp. Every token issued so
+-- far is at version 0, so users who revoked their tokens start at 1 and the
+-- revocation still holds.
+ALTER TABLE users
+ADD COLUMN token_version INTEGER NOT NULL DEFAULT 0;
+UPDATE users
+SET token_version = 1
+WHERE tokens_valid_after IS NOT NULL;
+ALTER TABLE users
+DROP tokens_valid_after;
+
+-- +goose Down
+ALTER TABLE users
+ADD COLUMN tokens_valid_after TIMESTAMP,
+DROP token_version;
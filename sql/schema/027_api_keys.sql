@@ -0,0 +1,18 @@
+-- +goose Up
+-- personal API keys act for their owner with a fixed set of scopes; only a
+-- hash of each key is kept
+CREATE TABLE api_keys (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    key_prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP
+);
+CREATE INDEX api_keys_user_id_idx ON api_keys (user_id, created_at);
+
+-- +goose Down
+DROP TABLE api_keys;
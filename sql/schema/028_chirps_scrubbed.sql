@@ -0,0 +1,15 @@
+-- +goose Up
+-- scrubbed tombstones have lost their body, revisions and attachments and
+-- can't be restored. Tombstones from before soft delete were emptied the
+-- same way when they were deleted.
+ALTER TABLE chirps
+ADD COLUMN scrubbed_at TIMESTAMP;
+UPDATE chirps
+SET scrubbed_at = deleted_at
+WHERE deleted_at IS NOT NULL
+AND body = ''
+AND kind <> 'rechirp';
+
+-- +goose Down
+ALTER TABLE chirps
+DROP COLUMN scrubbed_at;
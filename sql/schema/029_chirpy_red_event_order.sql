@@ -0,0 +1,9 @@
+-- +goose Up
+-- when Polka says each event happened, so late arrivals can't undo newer
+-- ones; the expiry sweeper's entries have none
+ALTER TABLE chirpy_red_history
+ADD COLUMN event_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE chirpy_red_history
+DROP COLUMN event_at;